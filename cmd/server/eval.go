@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/Alan-TheGentleman/gentleman-book-mcp/internal/embeddings"
+	"github.com/Alan-TheGentleman/gentleman-book-mcp/internal/eval"
+)
+
+// runEval implements the eval subcommand: it runs a golden set of queries
+// through keyword, semantic and hybrid search and prints recall@k, MRR and
+// nDCG per mode and locale.
+//
+//	gentleman-book-mcp eval -file golden.yaml [-k 5] [-modes keyword,semantic] [-rebuild] [-json]
+func runEval(args []string) {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	file := flags.String("file", "", "golden queries file (.json, .yaml or .yml)")
+	k := flags.Int("k", 0, "results scored per query (default: the file's k, or 5)")
+	modes := flags.String("modes", "", "comma-separated modes: keyword, semantic, hybrid (default: all available)")
+	rebuild := flags.Bool("rebuild", false, "index the book in memory instead of using the saved index")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	if *file == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	set, err := eval.LoadGoldenSet(*file)
+	if err != nil {
+		log.Fatalf("Error loading golden set: %v", err)
+	}
+
+	initParser()
+	initSemanticEngine()
+	defer closeSemanticEngine()
+
+	ctx := context.Background()
+	runner := &eval.Runner{Keyword: keywordHits}
+	if semanticEngine != nil {
+		if err := prepareEvalIndex(ctx, set, *rebuild); err != nil {
+			log.Fatalf("Error indexing the book: %v", err)
+		}
+		runner.Semantic = semanticHits
+	} else {
+		log.Println("Semantic search not available, evaluating keyword search only")
+	}
+
+	report, err := runner.Run(ctx, set, *k, splitList(*modes))
+	if err != nil {
+		log.Fatalf("Error running evaluation: %v", err)
+	}
+
+	if *asJSON {
+		reportJSON, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(reportJSON))
+		return
+	}
+	fmt.Print(report)
+}
+
+// prepareEvalIndex indexes in memory the golden set locales the saved index
+// is missing or cannot serve, or all of them when a rebuild is requested, so
+// chunking changes can be measured without touching the saved index
+func prepareEvalIndex(ctx context.Context, set *eval.GoldenSet, rebuild bool) error {
+	var locales []string
+	for _, q := range set.Queries {
+		if !slices.Contains(locales, q.Locale) {
+			locales = append(locales, q.Locale)
+		}
+	}
+	if !rebuild {
+		locales = missingLocales(locales)
+	}
+	if len(locales) == 0 {
+		return nil
+	}
+
+	chunks, err := collectChunks(locales)
+	if err != nil {
+		return err
+	}
+	log.Printf("Indexing %d chunks for %v", len(chunks), locales)
+
+	stats, err := semanticEngine.IndexChunks(ctx, chunks, nil)
+	if err != nil {
+		return err
+	}
+	if len(stats.Failures) > 0 {
+		log.Printf("%d chunks failed to embed and are left out", len(stats.Failures))
+	}
+	return nil
+}
+
+func keywordHits(ctx context.Context, query, locale string, k int) ([]eval.Hit, error) {
+	results, err := parser.Search(query, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]eval.Hit, len(results))
+	for i, r := range results {
+		hits[i] = eval.Hit{ChapterID: r.ChapterID, SectionID: r.SectionID}
+	}
+	return hits, nil
+}
+
+func semanticHits(ctx context.Context, query, locale string, k int) ([]eval.Hit, error) {
+	results, err := semanticEngine.Search(ctx, query, embeddings.SearchOptions{
+		TopK:          k,
+		Locale:        locale,
+		MaxPerSection: 1, // k distinct sections
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]eval.Hit, len(results))
+	for i, r := range results {
+		hits[i] = eval.Hit{ChapterID: r.ChapterID, SectionID: r.SectionID}
+	}
+	return hits, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Alan-TheGentleman/gentleman-book-mcp/internal/embeddings"
+)
+
+// runExportIndex implements the export_index subcommand: it writes the saved
+// semantic index to a portable file that import_index can load elsewhere.
+//
+//	gentleman-book-mcp export_index -out book-index.jsonl [-format jsonl|binary]
+func runExportIndex(args []string) {
+	flags := flag.NewFlagSet("export_index", flag.ExitOnError)
+	out := flags.String("out", "", "file to write, '-' for stdout")
+	format := flags.String("format", "", "jsonl or binary (default: binary for .bin files, jsonl otherwise)")
+	flags.Parse(args)
+
+	if *out == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *format == "" {
+		*format = embeddings.ExportJSONL
+		if strings.HasSuffix(*out, ".bin") {
+			*format = embeddings.ExportBinary
+		}
+	}
+
+	// The saved file is read directly, so exporting works offline
+	path := indexPath()
+	if _, err := os.Stat(path); err != nil {
+		log.Fatalf("No semantic index at %s, build it with 'build_semantic_index' first", path)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Error creating %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	header, err := embeddings.ExportIndexFile(path, w, *format)
+	if err != nil {
+		log.Fatalf("Error exporting index: %v", err)
+	}
+	log.Printf("Exported %d chunks (%s %s, %d dimensions) as %s", header.ChunkCount, header.Provider, header.Model, header.Dimensions, *format)
+}
+
+// runImportIndex implements the import_index subcommand: it validates an
+// export against the configured provider and saves it as the semantic index.
+//
+//	gentleman-book-mcp import_index -in book-index.jsonl
+func runImportIndex(args []string) {
+	flags := flag.NewFlagSet("import_index", flag.ExitOnError)
+	in := flags.String("in", "", "export file (jsonl or binary, detected automatically), '-' for stdin")
+	flags.Parse(args)
+
+	if *in == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			log.Fatalf("Error opening %s: %v", *in, err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	header, chunks, err := embeddings.ReadExport(r)
+	if err != nil {
+		log.Fatalf("Invalid export %s: %v", *in, err)
+	}
+
+	initSemanticEngine()
+	if semanticEngine == nil {
+		log.Fatalf("Semantic search not available, configure the %s provider to import this index", header.Provider)
+	}
+
+	// The provider must still produce vectors of the exported size, e.g. the
+	// same EMBEDDINGS_DIMENSIONS
+	if dims, err := semanticEngine.ProbeDimensions(context.Background()); err != nil {
+		log.Printf("Could not check the provider's vector size: %v", err)
+	} else if dims != header.Dimensions {
+		log.Fatalf("Export has %d dimensions but %s returns %d, it cannot be searched with this configuration", header.Dimensions, semanticEngine.Model(), dims)
+	}
+
+	if err := semanticEngine.Import(header, chunks); err != nil {
+		log.Fatalf("Cannot import %s: %v", *in, err)
+	}
+
+	path := indexPath()
+	if err := semanticEngine.SaveIndex(path); err != nil {
+		log.Fatalf("Error saving index: %v", err)
+	}
+	fmt.Printf("Imported %d chunks (%s %s, %d dimensions) into %s\n", len(chunks), header.Provider, header.Model, header.Dimensions, path)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/Alan-TheGentleman/gentleman-book-mcp/internal/fakeembed"
+)
+
+// runServeFakeEmbeddings implements the serve-fake-embeddings subcommand: a
+// stand-in OpenAI and Ollama embeddings server for demos and manual testing.
+//
+//	gentleman-book-mcp serve-fake-embeddings [-addr localhost:11435] [-dims 64] [-latency 50ms] [-error 429 -error-rate 0.1]
+func runServeFakeEmbeddings(args []string) {
+	flags := flag.NewFlagSet("serve-fake-embeddings", flag.ExitOnError)
+	addr := flags.String("addr", "localhost:11435", "address to listen on")
+	dims := flags.Int("dims", 64, "vector size")
+	latency := flags.Duration("latency", 0, "delay added to every request")
+	fault := flags.String("error", fakeembed.FaultServerError, "failure to inject: 429, 500 or malformed")
+	errorRate := flags.Float64("error-rate", 0, "share of requests that fail, between 0 and 1")
+	retryAfter := flags.Duration("retry-after", 0, "Retry-After sent with 429 responses")
+	legacy := flags.Bool("legacy-ollama", false, "serve only /api/embeddings, like Ollama before 0.3")
+	flags.Parse(args)
+
+	switch *fault {
+	case fakeembed.FaultRateLimit, fakeembed.FaultServerError, fakeembed.FaultMalformed:
+	default:
+		log.Fatalf("Unknown -error %q, use 429, 500 or malformed", *fault)
+	}
+
+	fake := fakeembed.New(fakeembed.Config{
+		Dimensions:   *dims,
+		Latency:      *latency,
+		RetryAfter:   *retryAfter,
+		Fault:        *fault,
+		ErrorRate:    *errorRate,
+		LegacyOllama: *legacy,
+	})
+
+	log.Printf("Fake embeddings server on http://%s (%d dimensions)", *addr, *dims)
+	log.Printf("OpenAI-compatible: EMBEDDINGS_BASE_URL=http://%s/v1 EMBEDDINGS_MODEL=fake", *addr)
+	log.Printf("Ollama: OLLAMA_BASE_URL=http://%s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, fake))
+}
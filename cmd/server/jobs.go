@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Alan-TheGentleman/gentleman-book-mcp/internal/embeddings"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Index job states
+const (
+	jobRunning   = "running"
+	jobCompleted = "completed"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// indexJob is a semantic index build running in the background
+type indexJob struct {
+	ID         string                 `json:"id"`
+	Locales    []string               `json:"locales"`
+	Mode       string                 `json:"mode"`
+	Status     string                 `json:"status"`
+	Done       int                    `json:"done"`
+	Total      int                    `json:"total"`
+	Chunks     int                    `json:"chunks"`
+	Stats      *embeddings.IndexStats `json:"stats,omitempty"`
+	Message    string                 `json:"message,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	StartedAt  time.Time              `json:"startedAt"`
+	FinishedAt *time.Time             `json:"finishedAt,omitempty"`
+
+	cancel context.CancelFunc
+	done   chan struct{} // closed when the job finishes
+}
+
+// maxFinishedJobs is how many finished jobs are kept for index_job_status
+const maxFinishedJobs = 20
+
+// jobManager tracks background index jobs. Running jobs are always kept;
+// only the most recent finished ones are.
+type jobManager struct {
+	mu     sync.Mutex
+	jobs   map[string]*indexJob
+	nextID int
+}
+
+var indexJobs = &jobManager{jobs: make(map[string]*indexJob)}
+
+// indexRequest describes what an index job should build
+type indexRequest struct {
+	locales []string
+	mode    string
+	chunks  []embeddings.Chunk
+}
+
+// start runs an index build in the background. ctx is only used for its
+// values (client session, progress token), never for cancellation: the job
+// outlives the tool call that created it.
+func (m *jobManager) start(ctx context.Context, req indexRequest, progressToken mcp.ProgressToken) *indexJob {
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	m.mu.Lock()
+	m.nextID++
+	job := &indexJob{
+		ID:        fmt.Sprintf("index-%d", m.nextID),
+		Locales:   req.locales,
+		Mode:      req.mode,
+		Status:    jobRunning,
+		Chunks:    len(req.chunks),
+		StartedAt: time.Now(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	m.jobs[job.ID] = job
+	m.mu.Unlock()
+
+	go m.run(jobCtx, job, req, progressToken)
+
+	return job
+}
+
+func (m *jobManager) run(ctx context.Context, job *indexJob, req indexRequest, progressToken mcp.ProgressToken) {
+	defer job.cancel()
+
+	progress := func(done, total int) {
+		m.mu.Lock()
+		job.Done, job.Total = done, total
+		m.mu.Unlock()
+
+		if progressToken != nil {
+			notifyProgress(ctx, progressToken, done, total, fmt.Sprintf("Embedded %d/%d chunks", done, total))
+		}
+	}
+
+	log.Printf("Index job %s: indexing %d chunks (%s)...", job.ID, len(req.chunks), req.mode)
+
+	var stats *embeddings.IndexStats
+	var err error
+	if req.mode == "full" {
+		stats, err = semanticEngine.IndexChunks(ctx, req.chunks, progress)
+	} else {
+		stats, err = semanticEngine.IndexIncremental(ctx, req.locales, req.chunks, progress)
+	}
+
+	message := ""
+	if err == nil {
+		message = fmt.Sprintf("Successfully indexed %d chunks from %d locale(s)", len(req.chunks)-stats.Failed, len(req.locales))
+		if req.mode != "full" {
+			message += fmt.Sprintf(": %d added, %d updated, %d removed, %d unchanged",
+				stats.Added, stats.Updated, stats.Removed, stats.Unchanged)
+		}
+		if stats.Failed > 0 {
+			message += fmt.Sprintf(". %d chunk(s) failed to embed, see 'stats.failures'", stats.Failed)
+		}
+		if saveErr := semanticEngine.SaveIndex(indexPath()); saveErr != nil {
+			log.Printf("Could not persist semantic index: %v", saveErr)
+			message += fmt.Sprintf(" (warning: index not saved to disk: %v)", saveErr)
+		}
+	}
+
+	m.mu.Lock()
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Stats = stats
+	switch {
+	case err == nil:
+		job.Status = jobCompleted
+		job.Message = message
+	case errors.Is(err, context.Canceled):
+		job.Status = jobCancelled
+		job.Message = "Cancelled, the previous index is still in use"
+	default:
+		job.Status = jobFailed
+		job.Error = err.Error()
+		var mismatch *embeddings.MismatchError
+		if errors.As(err, &mismatch) {
+			job.Error += ". Run 'build_semantic_index' with mode 'full'."
+		}
+	}
+	status := job.Status
+	m.evictFinished()
+	m.mu.Unlock()
+	close(job.done)
+
+	log.Printf("Index job %s %s", job.ID, status)
+	if progressToken != nil {
+		notifyProgress(ctx, progressToken, job.Total, job.Total, fmt.Sprintf("Index job %s", status))
+	}
+}
+
+// evictFinished drops the oldest finished jobs beyond maxFinishedJobs;
+// callers hold m.mu
+func (m *jobManager) evictFinished() {
+	var finished []*indexJob
+	for _, job := range m.jobs {
+		if job.FinishedAt != nil {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].FinishedAt.After(*finished[j].FinishedAt)
+	})
+	for _, job := range finished[maxFinishedJobs:] {
+		delete(m.jobs, job.ID)
+	}
+}
+
+// get returns a copy of a job so callers can marshal it without holding the lock
+func (m *jobManager) get(id string) (indexJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return indexJob{}, false
+	}
+	return *job, true
+}
+
+// list returns copies of all jobs, newest first
+func (m *jobManager) list() []indexJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]indexJob, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].StartedAt.After(jobs[j].StartedAt)
+	})
+	return jobs
+}
+
+// running returns a copy of the oldest running job building any of locales,
+// or of the oldest running job when no locales are given
+func (m *jobManager) running(locales ...string) (indexJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var oldest *indexJob
+	for _, job := range m.jobs {
+		if job.Status != jobRunning || (len(locales) > 0 && !job.builds(locales)) {
+			continue
+		}
+		if oldest == nil || job.StartedAt.Before(oldest.StartedAt) {
+			oldest = job
+		}
+	}
+	if oldest == nil {
+		return indexJob{}, false
+	}
+	return *oldest, true
+}
+
+// builds reports whether the job indexes any of locales
+func (j *indexJob) builds(locales []string) bool {
+	return slices.ContainsFunc(j.Locales, func(locale string) bool {
+		return slices.Contains(locales, locale)
+	})
+}
+
+// cancelJob cancels a running job
+func (m *jobManager) cancelJob(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("job not found: %s", id)
+	}
+	if job.Status != jobRunning {
+		return fmt.Errorf("job %s is already %s", id, job.Status)
+	}
+	job.cancel()
+	return nil
+}
+
+// notifyProgress sends an MCP progress notification to the client that started the job
+func notifyProgress(ctx context.Context, token mcp.ProgressToken, done, total int, message string) {
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return
+	}
+
+	params := map[string]any{
+		"progressToken": token,
+		"progress":      done,
+		"total":         total,
+		"message":       message,
+	}
+	if err := srv.SendNotificationToClient(ctx, "notifications/progress", params); err != nil {
+		log.Printf("Could not send progress notification: %v", err)
+	}
+}
@@ -3,11 +3,15 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"regexp"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Alan-TheGentleman/gentleman-book-mcp/internal/book"
 	"github.com/Alan-TheGentleman/gentleman-book-mcp/internal/embeddings"
@@ -19,23 +23,29 @@ var parser *book.Parser
 var semanticEngine *embeddings.SemanticEngine
 
 func main() {
-	// Get book path from environment variable or use default
-	bookPath := os.Getenv("BOOK_PATH")
-	if bookPath == "" {
-		// Default path relative to gentleman-programming-book project
-		homeDir, _ := os.UserHomeDir()
-		bookPath = homeDir + "/work/gentleman-programming-book/src/data/book"
-	}
-
-	// Verify path exists
-	if _, err := os.Stat(bookPath); os.IsNotExist(err) {
-		log.Fatalf("Book path does not exist: %s", bookPath)
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "eval":
+			runEval(os.Args[2:])
+			return
+		case "export_index":
+			runExportIndex(os.Args[2:])
+			return
+		case "import_index":
+			runImportIndex(os.Args[2:])
+			return
+		case "serve-fake-embeddings":
+			runServeFakeEmbeddings(os.Args[2:])
+			return
+		}
 	}
 
-	parser = book.NewParser(bookPath)
+	initParser()
 
 	// Initialize semantic engine if OpenAI API key or Ollama is available
 	initSemanticEngine()
+	warmUpSemanticIndex()
 
 	// Create MCP server
 	s := server.NewMCPServer(
@@ -128,30 +138,180 @@ func main() {
 			mcp.WithNumber("top_k",
 				mcp.Description("Number of results to return (default: 5)"),
 			),
+			mcp.WithNumber("diversity",
+				mcp.Description("0 ranks purely by similarity (default), up to 1 favors results that cover different content"),
+			),
+			mcp.WithNumber("max_per_section",
+				mcp.Description("Maximum results from the same section (default: no limit)"),
+			),
+			mcp.WithNumber("max_per_chapter",
+				mcp.Description("Maximum results from the same chapter (default: no limit)"),
+			),
+			mcp.WithNumber("min_relevance",
+				mcp.Description("Drop results scoring below this fraction of the best one, between 0 (default, keep all) and 1; fewer than top_k may come back"),
+			),
+			mcp.WithString("chapter_ids",
+				mcp.Description("Only search these chapters (comma-separated chapter IDs)"),
+			),
+			mcp.WithString("sections",
+				mcp.Description("Only search sections whose name contains any of these (comma-separated)"),
+			),
+			mcp.WithNumber("min_order",
+				mcp.Description("Only search chapters with at least this order"),
+			),
+			mcp.WithNumber("max_order",
+				mcp.Description("Only search chapters with at most this order"),
+			),
+			mcp.WithString("content_type",
+				mcp.Description("Only search 'code' (chunks with a code example) or 'prose' (chunks without code)"),
+			),
+			mcp.WithString("tags",
+				mcp.Description("Only search chapters with any of these frontmatter tags (comma-separated)"),
+			),
+			mcp.WithString("target_locales",
+				mcp.Description("Also search these editions (comma-separated, or 'all'); 'locale' is always searched too. Hits from other editions include their counterpart section in 'locale'. Needs a multilingual embedding model."),
+			),
 		),
 		handleSemanticSearch,
 	)
 
+	// Tool: find_related
+	s.AddTool(
+		mcp.NewTool("find_related",
+			mcp.WithDescription("Find the sections most similar to a chapter or section, elsewhere in the book. Uses the semantic index, no query needed."),
+			mcp.WithString("chapter_id",
+				mcp.Required(),
+				mcp.Description("The chapter to find related material for"),
+			),
+			mcp.WithString("section_id",
+				mcp.Description("A section of the chapter (tag ID from get_book_index). Without it the whole chapter is used."),
+			),
+			mcp.WithString("locale",
+				mcp.Description("Language locale: 'es' for Spanish, 'en' for English"),
+				mcp.DefaultString("es"),
+			),
+			mcp.WithNumber("top_k",
+				mcp.Description("Number of sections to return (default: 5)"),
+			),
+			mcp.WithNumber("max_per_chapter",
+				mcp.Description("Maximum sections from the same chapter (default: no limit)"),
+			),
+		),
+		handleFindRelated,
+	)
+
+	// Tool: topic_map
+	s.AddTool(
+		mcp.NewTool("topic_map",
+			mcp.WithDescription("Group the book by theme: clusters the semantic index into topics, each labeled with its top terms and listing its sections"),
+			mcp.WithString("locale",
+				mcp.Description("Language locale: 'es' for Spanish, 'en' for English"),
+				mcp.DefaultString("es"),
+			),
+			mcp.WithNumber("k",
+				mcp.Description("Number of topics (default: based on the size of the book)"),
+			),
+			mcp.WithNumber("terms",
+				mcp.Description("Terms listed per topic (default: 5)"),
+			),
+		),
+		handleTopicMap,
+	)
+
 	// Tool: build_semantic_index
 	s.AddTool(
 		mcp.NewTool("build_semantic_index",
-			mcp.WithDescription("Build or rebuild the semantic search index. Required before using semantic_search. Takes a few minutes."),
+			mcp.WithDescription("Start building or rebuilding the semantic search index in the background. Required before using semantic_search. Returns a job ID; follow it with index_job_status."),
 			mcp.WithString("locale",
 				mcp.Description("Language locale to index: 'es', 'en', or 'all'"),
 				mcp.DefaultString("all"),
 			),
+			mcp.WithString("mode",
+				mcp.Description("'incremental' embeds only new or changed chunks and drops deleted ones; 'full' re-embeds everything"),
+				mcp.DefaultString("incremental"),
+			),
 		),
 		handleBuildSemanticIndex,
 	)
 
+	// Tool: index_job_status
+	s.AddTool(
+		mcp.NewTool("index_job_status",
+			mcp.WithDescription("Check the progress of a background index build started by build_semantic_index. Lists running jobs and the last 20 finished ones if no job_id is given."),
+			mcp.WithString("job_id",
+				mcp.Description("Job ID returned by build_semantic_index"),
+			),
+		),
+		handleIndexJobStatus,
+	)
+
+	// Tool: cancel_index_job
+	s.AddTool(
+		mcp.NewTool("cancel_index_job",
+			mcp.WithDescription("Cancel a running background index build. The previous index keeps serving searches."),
+			mcp.WithString("job_id",
+				mcp.Required(),
+				mcp.Description("Job ID returned by build_semantic_index"),
+			),
+		),
+		handleCancelIndexJob,
+	)
+
 	// Tool: semantic_status
 	s.AddTool(
 		mcp.NewTool("semantic_status",
-			mcp.WithDescription("Check the status of the semantic search engine (availability, index status, chunk count)."),
+			mcp.WithDescription("Check the status of the semantic search engine: provider, base URL, model, dimensions, chunks per locale, last build, last error, latency of search, related and topic map queries, and cache stats."),
 		),
 		handleSemanticStatus,
 	)
 
+	// ============================================
+	// LEVEL 4: BOOK HISTORY
+	// ============================================
+
+	// Tool: chapter_history
+	s.AddTool(
+		mcp.NewTool("chapter_history",
+			mcp.WithDescription("List the commits that changed a chapter, newest first. Reads the git repository containing the book."),
+			mcp.WithString("chapter_id",
+				mcp.Required(),
+				mcp.Description("The chapter ID (e.g., 'clean-agile', 'hexagonal-architecture')"),
+			),
+			mcp.WithString("locale",
+				mcp.Description("Language locale: 'es' for Spanish, 'en' for English"),
+				mcp.DefaultString("es"),
+			),
+			mcp.WithNumber("limit",
+				mcp.Description("Maximum number of commits to return (default: 20)"),
+			),
+		),
+		handleChapterHistory,
+	)
+
+	// Tool: diff_chapter
+	s.AddTool(
+		mcp.NewTool("diff_chapter",
+			mcp.WithDescription("Show what changed in a chapter between two revisions, grouped by section. Revisions can be commit hashes, tags, branches or expressions like 'HEAD~3'."),
+			mcp.WithString("chapter_id",
+				mcp.Required(),
+				mcp.Description("The chapter ID (e.g., 'clean-agile', 'hexagonal-architecture')"),
+			),
+			mcp.WithString("from",
+				mcp.Required(),
+				mcp.Description("Older revision (e.g., 'v1.0', 'a1b2c3d', 'HEAD~5')"),
+			),
+			mcp.WithString("to",
+				mcp.Description("Newer revision (default: 'HEAD')"),
+				mcp.DefaultString("HEAD"),
+			),
+			mcp.WithString("locale",
+				mcp.Description("Language locale: 'es' for Spanish, 'en' for English"),
+				mcp.DefaultString("es"),
+			),
+		),
+		handleDiffChapter,
+	)
+
 	// ============================================
 	// LEVEL 2: DYNAMIC RESOURCES
 	// ============================================
@@ -177,6 +337,29 @@ func main() {
 		handleBookIndexResource,
 	)
 
+	// Resource: Topic map, only with a semantic engine to cluster the index
+	if semanticEngine != nil {
+		s.AddResource(
+			mcp.NewResource(
+				"book://topics/es",
+				"Topic Map (Spanish)",
+				mcp.WithResourceDescription("Themes of the Spanish version, clustered from the semantic index"),
+				mcp.WithMIMEType("application/json"),
+			),
+			handleTopicMapResource,
+		)
+
+		s.AddResource(
+			mcp.NewResource(
+				"book://topics/en",
+				"Topic Map (English)",
+				mcp.WithResourceDescription("Themes of the English version, clustered from the semantic index"),
+				mcp.WithMIMEType("application/json"),
+			),
+			handleTopicMapResource,
+		)
+	}
+
 	// ============================================
 	// LEVEL 2: PREDEFINED PROMPTS
 	// ============================================
@@ -225,7 +408,9 @@ func main() {
 
 	// Start server via stdio
 	log.Println("Starting Gentleman Book MCP Server...")
-	if err := server.ServeStdio(s); err != nil {
+	err := server.ServeStdio(s)
+	closeSemanticEngine()
+	if err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
@@ -326,6 +511,54 @@ func handleGetBookIndex(ctx context.Context, req mcp.CallToolRequest) (*mcp.Call
 	return mcp.NewToolResultText(string(result)), nil
 }
 
+// ============================================
+// TOOL HANDLERS - LEVEL 4
+// ============================================
+
+func handleChapterHistory(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chapterID := req.GetString("chapter_id", "")
+	locale := req.GetString("locale", "es")
+	limit := req.GetInt("limit", 20)
+
+	if chapterID == "" {
+		return mcp.NewToolResultError("chapter_id is required"), nil
+	}
+
+	revisions, err := parser.ChapterHistory(chapterID, locale, limit)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error reading chapter history: %v", err)), nil
+	}
+
+	if len(revisions) == 0 {
+		return mcp.NewToolResultText("No commits found for chapter: " + chapterID), nil
+	}
+
+	result, _ := json.MarshalIndent(revisions, "", "  ")
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func handleDiffChapter(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chapterID := req.GetString("chapter_id", "")
+	from := req.GetString("from", "")
+	to := req.GetString("to", "HEAD")
+	locale := req.GetString("locale", "es")
+
+	if chapterID == "" {
+		return mcp.NewToolResultError("chapter_id is required"), nil
+	}
+	if from == "" {
+		return mcp.NewToolResultError("from is required"), nil
+	}
+
+	diff, err := parser.DiffChapter(chapterID, locale, from, to)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error diffing chapter: %v", err)), nil
+	}
+
+	result, _ := json.MarshalIndent(diff, "", "  ")
+	return mcp.NewToolResultText(string(result)), nil
+}
+
 // ============================================
 // RESOURCE HANDLERS - LEVEL 2
 // ============================================
@@ -355,6 +588,34 @@ func handleBookIndexResource(ctx context.Context, req mcp.ReadResourceRequest) (
 	}, nil
 }
 
+func handleTopicMapResource(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	uri := req.Params.URI
+
+	if !semanticEngine.IsIndexed() {
+		return nil, fmt.Errorf("topic map needs a semantic index, run 'build_semantic_index' first")
+	}
+
+	locale := "es"
+	if strings.HasSuffix(uri, "/en") {
+		locale = "en"
+	}
+
+	topics, err := semanticEngine.TopicMap(locale, 0, 5)
+	if err != nil {
+		return nil, fmt.Errorf("error building topic map: %w", err)
+	}
+
+	topicsJSON, _ := json.MarshalIndent(topics, "", "  ")
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: "application/json",
+			Text:     string(topicsJSON),
+		},
+	}, nil
+}
+
 // ============================================
 // PROMPT HANDLERS - LEVEL 2
 // ============================================
@@ -541,13 +802,40 @@ Include:
 // ============================================
 
 func initSemanticEngine() {
-	// Try OpenAI first, then Ollama
+	// An explicit EMBEDDINGS_PROVIDER skips auto-detection
+	if provider := os.Getenv("EMBEDDINGS_PROVIDER"); provider != "" {
+		engine, err := embeddings.NewSemanticEngine(embeddings.Provider(provider))
+		if err != nil {
+			log.Printf("Semantic search not available with provider %s: %v", provider, err)
+			return
+		}
+		semanticEngine = engine
+		log.Printf("Semantic search enabled with %s (%s)", provider, engine.Model())
+		loadPersistedIndex()
+		return
+	}
+
+	// Try an explicitly configured OpenAI-compatible server, then OpenAI, then Ollama
 	var err error
 
+	if os.Getenv("EMBEDDINGS_BASE_URL") != "" {
+		semanticEngine, err = embeddings.NewSemanticEngine(embeddings.ProviderOpenAICompatible)
+		if err == nil && semanticEngine.IsAvailable() {
+			log.Printf("Semantic search enabled with OpenAI-compatible server at %s", os.Getenv("EMBEDDINGS_BASE_URL"))
+			loadPersistedIndex()
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("no embeddings returned by %s", os.Getenv("EMBEDDINGS_BASE_URL"))
+		}
+		log.Printf("OpenAI-compatible server not available: %v", err)
+	}
+
 	if os.Getenv("OPENAI_API_KEY") != "" {
 		semanticEngine, err = embeddings.NewSemanticEngine(embeddings.ProviderOpenAI)
 		if err == nil {
 			log.Println("Semantic search enabled with OpenAI")
+			loadPersistedIndex()
 			return
 		}
 		log.Printf("OpenAI not available: %v", err)
@@ -557,6 +845,7 @@ func initSemanticEngine() {
 	semanticEngine, err = embeddings.NewSemanticEngine(embeddings.ProviderOllama)
 	if err == nil && semanticEngine.IsAvailable() {
 		log.Println("Semantic search enabled with Ollama")
+		loadPersistedIndex()
 		return
 	}
 
@@ -564,24 +853,139 @@ func initSemanticEngine() {
 	semanticEngine = nil
 }
 
-func handleSemanticSearch(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+// closeSemanticEngine saves what the engine still holds in memory, such as
+// recent query cache entries, before the process exits
+func closeSemanticEngine() {
 	if semanticEngine == nil {
-		return mcp.NewToolResultError("Semantic search not available. Set OPENAI_API_KEY or ensure Ollama is running."), nil
+		return
 	}
+	if err := semanticEngine.Close(); err != nil {
+		log.Printf("Error closing semantic engine: %v", err)
+	}
+}
 
-	if !semanticEngine.IsIndexed() {
-		return mcp.NewToolResultError("Semantic index not built. Run 'build_semantic_index' first."), nil
+// initParser opens the book at BOOK_PATH, or the default location
+func initParser() {
+	// Get book path from environment variable or use default
+	bookPath := os.Getenv("BOOK_PATH")
+	if bookPath == "" {
+		// Default path relative to gentleman-programming-book project
+		homeDir, _ := os.UserHomeDir()
+		bookPath = homeDir + "/work/gentleman-programming-book/src/data/book"
+	}
+
+	// Verify path exists
+	if _, err := os.Stat(bookPath); os.IsNotExist(err) {
+		log.Fatalf("Book path does not exist: %s", bookPath)
+	}
+
+	parser = book.NewParser(bookPath)
+}
+
+// indexPath returns where the semantic index is persisted (INDEX_PATH or the user cache dir)
+func indexPath() string {
+	if path := os.Getenv("INDEX_PATH"); path != "" {
+		return path
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	return filepath.Join(cacheDir, "gentleman-book-mcp", "semantic-index.json.gz")
+}
+
+// loadPersistedIndex loads a previously built index so it survives restarts
+func loadPersistedIndex() {
+	path := indexPath()
+	meta, err := semanticEngine.LoadIndex(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Ignoring persisted semantic index at %s: %v", path, err)
+		}
+		return
+	}
+	log.Printf("Loaded semantic index from %s (%d chunks, built %s)", path, meta.ChunkCount, meta.BuiltAt.Format(time.RFC3339))
+	if err := semanticEngine.CheckIndex(); err != nil {
+		log.Printf("Semantic index unusable: %v", err)
+	}
+}
+
+func handleSemanticSearch(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if semanticEngine == nil {
+		return mcp.NewToolResultError("Semantic search not available. Set OPENAI_API_KEY or ensure Ollama is running."), nil
 	}
 
 	query := req.GetString("query", "")
-	locale := req.GetString("locale", "es")
-	topK := req.GetInt("top_k", 5)
+	opts := embeddings.SearchOptions{
+		TopK:          req.GetInt("top_k", 5),
+		Locale:        req.GetString("locale", "es"),
+		Diversity:     req.GetFloat("diversity", 0),
+		MaxPerSection: req.GetInt("max_per_section", 0),
+		MaxPerChapter: req.GetInt("max_per_chapter", 0),
+		MinRelevance:  req.GetFloat("min_relevance", 0),
+		Filter: &embeddings.Filter{
+			ChapterIDs:  splitList(req.GetString("chapter_ids", "")),
+			Sections:    splitList(req.GetString("sections", "")),
+			MinOrder:    req.GetInt("min_order", 0),
+			MaxOrder:    req.GetInt("max_order", 0),
+			ContentType: req.GetString("content_type", ""),
+			Tags:        splitList(req.GetString("tags", "")),
+		},
+	}
 
 	if query == "" {
 		return mcp.NewToolResultError("query is required"), nil
 	}
+	if opts.Diversity < 0 || opts.Diversity > 1 {
+		return mcp.NewToolResultError("diversity must be between 0 and 1"), nil
+	}
+	if opts.MinRelevance < 0 || opts.MinRelevance > 1 {
+		return mcp.NewToolResultError("min_relevance must be between 0 and 1"), nil
+	}
+	if ct := opts.Filter.ContentType; ct != "" && ct != book.ContentProse && ct != book.ContentCode {
+		return mcp.NewToolResultError("content_type must be 'prose' or 'code'"), nil
+	}
 
-	results, err := semanticEngine.Search(ctx, query, locale, topK)
+	targetLocales := splitList(req.GetString("target_locales", ""))
+	if len(targetLocales) == 1 && targetLocales[0] == "all" {
+		locales, err := parser.GetAvailableLocales()
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Error listing locales: %v", err)), nil
+		}
+		targetLocales = locales
+	}
+	// The reader's own edition is always searched along with the targets
+	if len(targetLocales) > 0 {
+		opts.Locales = []string{opts.Locale}
+	}
+	for _, locale := range targetLocales {
+		if !slices.Contains(opts.Locales, locale) {
+			opts.Locales = append(opts.Locales, locale)
+		}
+	}
+
+	// While a build of a searched locale is running, wait for it a little,
+	// then answer with keyword search
+	searched := opts.Locales
+	if len(searched) == 0 {
+		searched = []string{opts.Locale}
+	}
+	job, building, err := waitForIndex(ctx, searched)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Search error: %v", err)), nil
+	}
+	if building {
+		return keywordFallback(job, query, opts.Locale, opts.TopK)
+	}
+	if !semanticEngine.IsIndexed() {
+		return mcp.NewToolResultError("Semantic index not built. Run 'build_semantic_index' first."), nil
+	}
+
+	results, err := semanticEngine.Search(ctx, query, opts)
+	var mismatch *embeddings.MismatchError
+	if errors.As(err, &mismatch) {
+		return mcp.NewToolResultError(fmt.Sprintf("Search error: %v. Run 'build_semantic_index' with mode 'full'.", err)), nil
+	}
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Search error: %v", err)), nil
 	}
@@ -590,16 +994,110 @@ func handleSemanticSearch(ctx context.Context, req mcp.CallToolRequest) (*mcp.Ca
 		return mcp.NewToolResultText("No semantic matches found for: " + query), nil
 	}
 
+	if len(opts.Locales) > 0 {
+		crossResults, err := withCounterparts(results, opts.Locale)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Error aligning editions: %v", err)), nil
+		}
+		resultJSON, _ := json.MarshalIndent(crossResults, "", "  ")
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	}
+
+	resultJSON, _ := json.MarshalIndent(results, "", "  ")
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+func handleFindRelated(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if semanticEngine == nil {
+		return mcp.NewToolResultError("Semantic search not available. Set OPENAI_API_KEY or ensure Ollama is running."), nil
+	}
+
+	if !semanticEngine.IsIndexed() {
+		return mcp.NewToolResultError("Semantic index not built. Run 'build_semantic_index' first."), nil
+	}
+
+	chapterID := req.GetString("chapter_id", "")
+	sectionID := req.GetString("section_id", "")
+	opts := embeddings.SearchOptions{
+		TopK:          req.GetInt("top_k", 5),
+		Locale:        req.GetString("locale", "es"),
+		MaxPerSection: 1, // one chunk per section, the best matching one
+		MaxPerChapter: req.GetInt("max_per_chapter", 0),
+	}
+
+	results, err := semanticEngine.Related(chapterID, sectionID, opts)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error finding related sections: %v", err)), nil
+	}
+
+	if len(results) == 0 {
+		return mcp.NewToolResultText("No related sections found for: " + chapterID), nil
+	}
+
 	resultJSON, _ := json.MarshalIndent(results, "", "  ")
 	return mcp.NewToolResultText(string(resultJSON)), nil
 }
 
+func handleTopicMap(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if semanticEngine == nil {
+		return mcp.NewToolResultError("Semantic search not available. Set OPENAI_API_KEY or ensure Ollama is running."), nil
+	}
+
+	if !semanticEngine.IsIndexed() {
+		return mcp.NewToolResultError("Semantic index not built. Run 'build_semantic_index' first."), nil
+	}
+
+	topics, err := semanticEngine.TopicMap(req.GetString("locale", "es"), req.GetInt("k", 0), req.GetInt("terms", 5))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error building topic map: %v", err)), nil
+	}
+
+	resultJSON, _ := json.MarshalIndent(topics, "", "  ")
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// crossLingualResult is a semantic hit from any edition, with the matching
+// section in the reader's locale when the hit comes from another one
+type crossLingualResult struct {
+	embeddings.SemanticResult
+	Counterpart *book.SectionRef `json:"counterpart,omitempty"`
+}
+
+// withCounterparts maps every hit outside locale to its aligned section in locale
+func withCounterparts(results []embeddings.SemanticResult, locale string) ([]crossLingualResult, error) {
+	locales := []string{locale}
+	for _, r := range results {
+		if !slices.Contains(locales, r.Locale) {
+			locales = append(locales, r.Locale)
+		}
+	}
+
+	aligner, err := parser.NewAligner(locales...)
+	if err != nil {
+		return nil, err
+	}
+
+	crossResults := make([]crossLingualResult, len(results))
+	for i, r := range results {
+		crossResults[i].SemanticResult = r
+		if r.Locale != locale {
+			crossResults[i].Counterpart = aligner.Align(r.ChapterID, r.SectionID, r.Locale, locale)
+		}
+	}
+	return crossResults, nil
+}
+
 func handleBuildSemanticIndex(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	if semanticEngine == nil {
 		return mcp.NewToolResultError("Semantic search not available. Set OPENAI_API_KEY or ensure Ollama is running."), nil
 	}
 
 	localeParam := req.GetString("locale", "all")
+	mode := req.GetString("mode", "incremental")
+
+	if mode != "incremental" && mode != "full" {
+		return mcp.NewToolResultError("mode must be 'incremental' or 'full'"), nil
+	}
 
 	var locales []string
 	if localeParam == "all" {
@@ -608,142 +1106,140 @@ func handleBuildSemanticIndex(ctx context.Context, req mcp.CallToolRequest) (*mc
 		locales = []string{localeParam}
 	}
 
-	var allChunks []embeddings.Chunk
-	chunkID := 0
-
-	for _, locale := range locales {
-		chapters, err := parser.ListChapters(locale)
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Error reading chapters for %s: %v", locale, err)), nil
-		}
-
-		for _, chapter := range chapters {
-			// Split content into chunks (by sections or paragraphs)
-			chunks := splitIntoChunks(chapter.Content, chapter.ID, chapter.Name, locale, &chunkID)
-			allChunks = append(allChunks, chunks...)
-		}
+	allChunks, err := collectChunks(locales)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error preparing chunks: %v", err)), nil
 	}
 
-	log.Printf("Indexing %d chunks...", len(allChunks))
-
-	if err := semanticEngine.IndexChunks(ctx, allChunks); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Error indexing: %v", err)), nil
+	var progressToken mcp.ProgressToken
+	if req.Params.Meta != nil {
+		progressToken = req.Params.Meta.ProgressToken
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Successfully indexed %d chunks from %d locale(s)", len(allChunks), len(locales))), nil
+	job := indexJobs.start(ctx, indexRequest{locales: locales, mode: mode, chunks: allChunks}, progressToken)
+
+	result, _ := json.MarshalIndent(map[string]any{
+		"jobId":   job.ID,
+		"status":  job.Status,
+		"chunks":  job.Chunks,
+		"message": fmt.Sprintf("Indexing started in the background. Use 'index_job_status' with job_id '%s' to follow it.", job.ID),
+	}, "", "  ")
+	return mcp.NewToolResultText(string(result)), nil
 }
 
-func handleSemanticStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	status := map[string]interface{}{
-		"available": semanticEngine != nil,
-		"indexed":   false,
-		"chunks":    0,
-		"provider":  "none",
-	}
+func handleIndexJobStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jobID := req.GetString("job_id", "")
 
-	if semanticEngine != nil {
-		status["indexed"] = semanticEngine.IsIndexed()
-		status["chunks"] = semanticEngine.ChunkCount()
+	if jobID == "" {
+		result, _ := json.MarshalIndent(indexJobs.list(), "", "  ")
+		return mcp.NewToolResultText(string(result)), nil
+	}
 
-		if os.Getenv("OPENAI_API_KEY") != "" {
-			status["provider"] = "openai"
-		} else {
-			status["provider"] = "ollama"
-		}
+	job, ok := indexJobs.get(jobID)
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Job not found: %s", jobID)), nil
 	}
 
-	result, _ := json.MarshalIndent(status, "", "  ")
+	result, _ := json.MarshalIndent(job, "", "  ")
 	return mcp.NewToolResultText(string(result)), nil
 }
 
-// splitIntoChunks splits content into manageable chunks
-func splitIntoChunks(content string, chapterID, chapterName, locale string, idCounter *int) []embeddings.Chunk {
-	var chunks []embeddings.Chunk
-
-	// Split by sections (## headers)
-	headerPattern := regexp.MustCompile(`(?m)^##\s+(.+)$`)
-	sections := headerPattern.Split(content, -1)
-	headers := headerPattern.FindAllStringSubmatch(content, -1)
-
-	// Add content before the first header
-	if len(sections) > 0 && strings.TrimSpace(sections[0]) != "" {
-		*idCounter++
-		chunks = append(chunks, embeddings.Chunk{
-			ID:          fmt.Sprintf("chunk_%d", *idCounter),
-			ChapterID:   chapterID,
-			ChapterName: chapterName,
-			Section:     "Introduction",
-			Content:     truncateContent(strings.TrimSpace(sections[0]), 1000),
-			Locale:      locale,
-		})
-	}
+func handleCancelIndexJob(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jobID := req.GetString("job_id", "")
 
-	// Process each section
-	for i, header := range headers {
-		sectionContent := ""
-		if i+1 < len(sections) {
-			sectionContent = strings.TrimSpace(sections[i+1])
-		}
+	if jobID == "" {
+		return mcp.NewToolResultError("job_id is required"), nil
+	}
 
-		if sectionContent == "" {
-			continue
-		}
+	if err := indexJobs.cancelJob(jobID); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error cancelling job: %v", err)), nil
+	}
 
-		// If content is too long, split into smaller chunks
-		sectionName := header[1]
-		contentChunks := splitLongContent(sectionContent, 1000)
+	return mcp.NewToolResultText(fmt.Sprintf("Cancellation requested for job %s", jobID)), nil
+}
 
-		for j, c := range contentChunks {
-			*idCounter++
-			suffix := ""
-			if len(contentChunks) > 1 {
-				suffix = fmt.Sprintf(" (part %d)", j+1)
-			}
-			chunks = append(chunks, embeddings.Chunk{
-				ID:          fmt.Sprintf("chunk_%d", *idCounter),
-				ChapterID:   chapterID,
-				ChapterName: chapterName,
-				Section:     sectionName + suffix,
-				Content:     c,
-				Locale:      locale,
-			})
+// splitList splits a comma-separated parameter, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-
-	return chunks
+	return items
 }
 
-func splitLongContent(content string, maxLen int) []string {
-	if len(content) <= maxLen {
-		return []string{content}
+// chunkerConfig reads CHUNK_STRATEGY, CHUNK_MAX_TOKENS and CHUNK_OVERLAP_TOKENS
+func chunkerConfig() book.ChunkerConfig {
+	cfg := book.DefaultChunkerConfig()
+	if strategy := os.Getenv("CHUNK_STRATEGY"); strategy != "" {
+		cfg.Strategy = strategy
 	}
+	if n, err := strconv.Atoi(os.Getenv("CHUNK_MAX_TOKENS")); err == nil {
+		cfg.MaxTokens = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("CHUNK_OVERLAP_TOKENS")); err == nil {
+		cfg.OverlapTokens = n
+	}
+	return cfg
+}
+
+// collectChunks splits every chapter of the given locales into chunks
+func collectChunks(locales []string) ([]embeddings.Chunk, error) {
+	var allChunks []embeddings.Chunk
+	cfg := chunkerConfig()
 
-	var chunks []string
-	paragraphs := strings.Split(content, "\n\n")
-	current := ""
+	for _, locale := range locales {
+		chapters, err := parser.ListChapters(locale)
+		if err != nil {
+			return nil, fmt.Errorf("error reading chapters for %s: %w", locale, err)
+		}
 
-	for _, p := range paragraphs {
-		if len(current)+len(p) > maxLen && current != "" {
-			chunks = append(chunks, strings.TrimSpace(current))
-			current = p
-		} else {
-			if current != "" {
-				current += "\n\n"
+		for _, chapter := range chapters {
+			for _, text := range parser.ChunkChapter(&chapter, cfg) {
+				chunk := embeddings.Chunk{
+					ChapterID:    chapter.ID,
+					ChapterName:  chapter.Name,
+					Section:      text.Section,
+					SectionID:    text.SectionID,
+					Breadcrumb:   text.Breadcrumb,
+					Content:      text.Content,
+					Locale:       locale,
+					ChapterOrder: chapter.Order,
+					ContentType:  book.ContentType(text.Content),
+					Tags:         chapter.Tags,
+				}
+				chunk.ID = embeddings.ChunkID(locale, chapter.ID, chunk.Section, chunk.EmbedText())
+				allChunks = append(allChunks, chunk)
 			}
-			current += p
 		}
 	}
 
-	if current != "" {
-		chunks = append(chunks, strings.TrimSpace(current))
-	}
+	return allChunks, nil
+}
 
-	return chunks
+// semanticStatus is the result of semantic_status
+type semanticStatus struct {
+	Available bool `json:"available"`
+	*embeddings.Status
+	IndexJob *indexJob `json:"indexJob,omitempty"` // build running right now
+	Error    string    `json:"error,omitempty"`
 }
 
-func truncateContent(content string, maxLen int) string {
-	if len(content) <= maxLen {
-		return content
+func handleSemanticStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	status := semanticStatus{Available: semanticEngine != nil}
+
+	if semanticEngine != nil {
+		engineStatus := semanticEngine.Status()
+		status.Status = &engineStatus
+		if job, ok := indexJobs.running(); ok {
+			status.IndexJob = &job
+		}
+		if err := semanticEngine.CheckIndex(); err != nil {
+			status.Error = err.Error()
+		}
 	}
-	return content[:maxLen] + "..."
+
+	result, _ := json.MarshalIndent(status, "", "  ")
+	return mcp.NewToolResultText(string(result)), nil
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultSearchWait is how long semantic_search waits for a running build
+// before answering with keyword results
+const defaultSearchWait = 5 * time.Second
+
+// warmUpLocales reads SEMANTIC_WARMUP: a comma-separated list of locales,
+// "all" or "true" for every edition. Empty or "false" disables the warm-up.
+func warmUpLocales() []string {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv("SEMANTIC_WARMUP")))
+	switch value {
+	case "", "false", "0", "no":
+		return nil
+	case "all", "true", "1", "yes":
+		return []string{"es", "en"}
+	}
+	return splitList(value)
+}
+
+// searchWait reads SEMANTIC_SEARCH_WAIT, a Go duration; 0 answers with
+// keyword results right away
+func searchWait() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SEMANTIC_SEARCH_WAIT")); err == nil && d >= 0 {
+		return d
+	}
+	return defaultSearchWait
+}
+
+// warmUpSemanticIndex builds, in the background at startup, the locales of
+// SEMANTIC_WARMUP that the index loaded from disk does not cover
+func warmUpSemanticIndex() {
+	if semanticEngine == nil {
+		return
+	}
+	locales := missingLocales(warmUpLocales())
+	if len(locales) == 0 {
+		return
+	}
+
+	chunks, err := collectChunks(locales)
+	if err != nil {
+		log.Printf("Semantic warm-up skipped: %v", err)
+		return
+	}
+
+	job := indexJobs.start(context.Background(), indexRequest{locales: locales, mode: "full", chunks: chunks}, nil)
+	log.Printf("Semantic warm-up: building the index for %v in the background (job %s)", locales, job.ID)
+}
+
+// missingLocales returns the locales semantic search cannot answer for yet:
+// every one of them when the index was built with another model, otherwise
+// those without indexed chunks
+func missingLocales(locales []string) []string {
+	if semanticEngine.CheckIndex() != nil {
+		return locales
+	}
+	var missing []string
+	for _, locale := range locales {
+		if !semanticEngine.IsLocaleIndexed(locale) {
+			missing = append(missing, locale)
+		}
+	}
+	return missing
+}
+
+// waitForIndex waits up to SEMANTIC_SEARCH_WAIT for running index builds to
+// make the index usable for every given locale. It returns the job still
+// building one of them when the wait runs out, false when they are ready or
+// nothing is building them, and the context error when the request is cancelled.
+func waitForIndex(ctx context.Context, locales []string) (indexJob, bool, error) {
+	timeout := time.NewTimer(searchWait())
+	defer timeout.Stop()
+
+	for {
+		missing := missingLocales(locales)
+		if len(missing) == 0 {
+			return indexJob{}, false, nil
+		}
+		job, ok := indexJobs.running(missing...)
+		if !ok {
+			return indexJob{}, false, nil
+		}
+		select {
+		case <-job.done:
+		case <-timeout.C:
+			return job, true, nil
+		case <-ctx.Done():
+			return indexJob{}, false, ctx.Err()
+		}
+	}
+}
+
+// keywordFallback answers a semantic query with keyword search while the
+// index is being built, saying so in the result
+func keywordFallback(job indexJob, query, locale string, topK int) (*mcp.CallToolResult, error) {
+	results, err := parser.Search(query, locale)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Error searching: %v", err)), nil
+	}
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+
+	progress := "starting"
+	if job.Total > 0 {
+		progress = fmt.Sprintf("%d/%d chunks", job.Done, job.Total)
+	}
+
+	result, _ := json.MarshalIndent(map[string]any{
+		"searchMode": "keyword",
+		"message": fmt.Sprintf("The semantic index is still being built (job %s, %s), so these are keyword search results. "+
+			"Try semantic_search again later or follow the build with 'index_job_status'.", job.ID, progress),
+		"jobId":   job.ID,
+		"results": results,
+	}, "", "  ")
+	return mcp.NewToolResultText(string(result)), nil
+}
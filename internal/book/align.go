@@ -0,0 +1,89 @@
+package book
+
+// Aligner maps chapters and sections between editions of the book. Chapters
+// are matched by ID, or by order when the editions use different IDs.
+// Sections are matched by position in the titleList, or by position among
+// the chapter headers when the titleList does not list them.
+type Aligner struct {
+	parser   *Parser
+	chapters map[string][]Chapter // by locale
+}
+
+// NewAligner loads the chapters of the given locales
+func (p *Parser) NewAligner(locales ...string) (*Aligner, error) {
+	a := &Aligner{parser: p, chapters: make(map[string][]Chapter)}
+	for _, locale := range locales {
+		chapters, err := p.ListChapters(locale)
+		if err != nil {
+			return nil, err
+		}
+		a.chapters[locale] = chapters
+	}
+	return a, nil
+}
+
+// Align returns the counterpart of a section in another locale, or nil if the
+// chapter has no counterpart. When only the chapter can be matched, the
+// returned reference has no section.
+func (a *Aligner) Align(chapterID, sectionID, fromLocale, toLocale string) *SectionRef {
+	source := a.chapter(fromLocale, func(c *Chapter) bool { return c.ID == chapterID })
+	target := a.chapter(toLocale, func(c *Chapter) bool { return c.ID == chapterID })
+	if target == nil && source != nil {
+		target = a.chapter(toLocale, func(c *Chapter) bool { return c.Order == source.Order })
+	}
+	if target == nil {
+		return nil
+	}
+
+	ref := &SectionRef{ChapterID: target.ID, ChapterName: target.Name, Locale: toLocale}
+	if sectionID == "" || source == nil {
+		return ref
+	}
+
+	if i := titleIndex(source.TitleList, sectionID); i >= 0 && i < len(target.TitleList) {
+		ref.SectionID = target.TitleList[i].TagID
+		ref.Section = target.TitleList[i].Name
+		return ref
+	}
+
+	sourceSections := a.headers(source)
+	targetSections := a.headers(target)
+	for i, section := range sourceSections {
+		if section.tagID == sectionID && i < len(targetSections) {
+			ref.SectionID = targetSections[i].tagID
+			ref.Section = targetSections[i].title
+			break
+		}
+	}
+	return ref
+}
+
+func (a *Aligner) chapter(locale string, match func(*Chapter) bool) *Chapter {
+	chapters := a.chapters[locale]
+	for i := range chapters {
+		if match(&chapters[i]) {
+			return &chapters[i]
+		}
+	}
+	return nil
+}
+
+// headers returns the sections of a chapter that start with a header
+func (a *Aligner) headers(chapter *Chapter) []chapterSection {
+	var sections []chapterSection
+	for _, section := range a.parser.splitSections(chapter.Content) {
+		if section.level > 0 {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+func titleIndex(titles []Section, tagID string) int {
+	for i, title := range titles {
+		if title.TagID == tagID {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,266 @@
+package book
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Chunking strategies
+const (
+	ChunkByHeading  = "heading"  // one chunk per section, split at paragraphs when too long
+	ChunkByWindow   = "window"   // fixed-size token windows that overlap
+	ChunkBySentence = "sentence" // whole sentences packed up to the size limit, overlapping
+)
+
+// ChunkerConfig controls how chapters are split for embedding
+type ChunkerConfig struct {
+	Strategy      string
+	MaxTokens     int // chunk size limit; a code block longer than this stays whole
+	OverlapTokens int // tokens repeated between consecutive chunks (window and sentence)
+}
+
+// DefaultChunkerConfig splits by heading into chunks of up to 300 tokens
+func DefaultChunkerConfig() ChunkerConfig {
+	return ChunkerConfig{
+		Strategy:      ChunkByHeading,
+		MaxTokens:     300,
+		OverlapTokens: 40,
+	}
+}
+
+// TextChunk is a piece of a chapter sized for embedding
+type TextChunk struct {
+	Section    string // header of the section, with " (part N)" when it was split
+	SectionID  string // tag ID of the section, empty for the introduction
+	Breadcrumb string // "Chapter > Section > Subsection"
+	Content    string
+	Tokens     int
+}
+
+// EstimateTokens approximates how many tokens an embedding model sees in
+// text, at about four characters per token for English and Spanish. The
+// embeddings package sizes batches and rate limits with it too.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// ChunkChapter splits a chapter into chunks following cfg. Every section of
+// any level is chunked on its own; code blocks are never cut.
+func (p *Parser) ChunkChapter(chapter *Chapter, cfg ChunkerConfig) []TextChunk {
+	defaults := DefaultChunkerConfig()
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = defaults.MaxTokens
+	}
+	cfg.OverlapTokens = min(max(cfg.OverlapTokens, 0), cfg.MaxTokens/2)
+	if cfg.Strategy != ChunkByWindow && cfg.Strategy != ChunkBySentence {
+		cfg.Strategy = ChunkByHeading
+		cfg.OverlapTokens = 0
+	}
+
+	var chunks []TextChunk
+	var parents []chapterSection // enclosing sections, for breadcrumbs
+
+	for _, section := range p.splitSections(chapter.Content) {
+		body := section.content
+		if section.level > 0 {
+			// Drop the header line, the breadcrumb carries it
+			_, body, _ = strings.Cut(body, "\n")
+		}
+
+		for len(parents) > 0 && parents[len(parents)-1].level >= section.level {
+			parents = parents[:len(parents)-1]
+		}
+		crumbs := []string{chapter.Name}
+		for _, parent := range parents {
+			crumbs = append(crumbs, parent.title)
+		}
+		if section.level > 0 {
+			crumbs = append(crumbs, section.title)
+			parents = append(parents, section)
+		}
+
+		texts := pack(splitPieces(body, cfg), cfg.MaxTokens, cfg.OverlapTokens)
+		for i, text := range texts {
+			name := section.title
+			if len(texts) > 1 {
+				name = fmt.Sprintf("%s (part %d)", section.title, i+1)
+			}
+			chunks = append(chunks, TextChunk{
+				Section:    name,
+				SectionID:  section.tagID,
+				Breadcrumb: strings.Join(crumbs, " > "),
+				Content:    text,
+				Tokens:     EstimateTokens(text),
+			})
+		}
+	}
+
+	return chunks
+}
+
+// piece is an unbreakable run of text: a paragraph, sentence, word or code block
+type piece struct {
+	text   string
+	tokens int
+}
+
+func newPiece(text string) piece {
+	return piece{text: text, tokens: EstimateTokens(text)}
+}
+
+// splitPieces cuts a section body into the units the strategy packs together
+func splitPieces(body string, cfg ChunkerConfig) []piece {
+	var pieces []piece
+	for _, block := range splitBlocks(body) {
+		switch {
+		case block.code:
+			pieces = append(pieces, newPiece(block.text))
+		case cfg.Strategy == ChunkByWindow:
+			pieces = append(pieces, splitWords(block.text)...)
+		case cfg.Strategy == ChunkBySentence:
+			pieces = append(pieces, fitPieces(splitSentences(block.text), cfg.MaxTokens)...)
+		default:
+			pieces = append(pieces, fitPieces([]piece{newPiece(block.text)}, cfg.MaxTokens)...)
+		}
+	}
+	return pieces
+}
+
+// fitPieces breaks pieces larger than maxTokens into sentences, and those
+// into words
+func fitPieces(pieces []piece, maxTokens int) []piece {
+	var fitted []piece
+	for _, p := range pieces {
+		if p.tokens <= maxTokens {
+			fitted = append(fitted, p)
+			continue
+		}
+		if sentences := splitSentences(p.text); len(sentences) > 1 {
+			fitted = append(fitted, fitPieces(sentences, maxTokens)...)
+		} else {
+			fitted = append(fitted, splitWords(p.text)...)
+		}
+	}
+	return fitted
+}
+
+// pack joins consecutive pieces into texts of at most maxTokens, starting
+// each text with up to overlap tokens from the end of the previous one
+func pack(pieces []piece, maxTokens, overlap int) []string {
+	var texts []string
+	for start := 0; start < len(pieces); {
+		end, tokens := start, 0
+		for end < len(pieces) && (end == start || tokens+pieces[end].tokens <= maxTokens) {
+			tokens += pieces[end].tokens
+			end++
+		}
+
+		var text strings.Builder
+		for _, p := range pieces[start:end] {
+			text.WriteString(p.text)
+		}
+		if t := strings.TrimSpace(text.String()); t != "" {
+			texts = append(texts, t)
+		}
+
+		if end == len(pieces) {
+			break
+		}
+
+		// Step back over the overlap, always moving forward at least one piece
+		// and leaving room for the next new piece
+		next, overlapped := end, 0
+		for next-1 > start && overlapped+pieces[next-1].tokens <= overlap &&
+			overlapped+pieces[next-1].tokens+pieces[end].tokens <= maxTokens {
+			next--
+			overlapped += pieces[next].tokens
+		}
+		start = next
+	}
+	return texts
+}
+
+// block is a paragraph, a header line or a fenced code block
+type block struct {
+	text string
+	code bool
+}
+
+// splitBlocks cuts text at blank lines, keeping fenced code blocks whole.
+// Each block keeps its trailing blank line so joined blocks read naturally.
+func splitBlocks(text string) []block {
+	var blocks []block
+	var current strings.Builder
+	var fence codeFence
+
+	flush := func(code bool) {
+		if strings.TrimSpace(current.String()) != "" {
+			blocks = append(blocks, block{text: strings.TrimRight(current.String(), "\n") + "\n\n", code: code})
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		inCode := fence.inside()
+		switch {
+		case fence.toggle(line):
+			if !inCode {
+				flush(false)
+			}
+			current.WriteString(line + "\n")
+			if inCode {
+				flush(true)
+			}
+		case inCode:
+			current.WriteString(line + "\n")
+		case strings.TrimSpace(line) == "":
+			flush(false)
+		default:
+			current.WriteString(line + "\n")
+		}
+	}
+	flush(fence.inside())
+
+	return blocks
+}
+
+// sentenceEnd matches the end of a sentence: terminal punctuation, optional
+// closing quotes or brackets, then whitespace
+var sentenceEnd = regexp.MustCompile(`[.!?…]["'”’)\]]*\s+`)
+
+// splitSentences cuts text after each sentence, keeping the whitespace that
+// follows it
+func splitSentences(text string) []piece {
+	var pieces []piece
+	start := 0
+	for _, loc := range sentenceEnd.FindAllStringIndex(text, -1) {
+		// Skip abbreviations and decimals like "e.g. this" or "v1.2 is": the next
+		// sentence should start with an uppercase letter, a digit or markup
+		if loc[1] < len(text) {
+			next, _ := utf8.DecodeRuneInString(text[loc[1]:])
+			if unicode.IsLower(next) {
+				continue
+			}
+		}
+		pieces = append(pieces, newPiece(text[start:loc[1]]))
+		start = loc[1]
+	}
+	if start < len(text) {
+		pieces = append(pieces, newPiece(text[start:]))
+	}
+	return pieces
+}
+
+var wordPattern = regexp.MustCompile(`\S+\s*`)
+
+// splitWords cuts text into words, keeping the whitespace after each one
+func splitWords(text string) []piece {
+	var pieces []piece
+	for _, word := range wordPattern.FindAllString(text, -1) {
+		pieces = append(pieces, newPiece(word))
+	}
+	return pieces
+}
@@ -0,0 +1,128 @@
+package book
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// sentences returns n distinct sentences of about ten tokens each
+func sentences(n int) string {
+	var b strings.Builder
+	for i := range n {
+		fmt.Fprintf(&b, "Sentence number %d explains one more idea about hooks. ", i+1)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// codeBlock is a fenced block of about 150 tokens with blank lines and a
+// header-looking comment inside
+var codeBlock = "```ts\n" + strings.Repeat("const value = computeSomething(input)\n", 8) +
+	"\n## not a header\n\n" + strings.Repeat("const other = computeSomethingElse(input)\n", 6) + "```"
+
+func chunkTestChapter() *Chapter {
+	return &Chapter{
+		ID:   "hooks",
+		Name: "Hooks",
+		Content: "Intro.\n\n## State\n\n" + sentences(40) + "\n\n" + sentences(3) + "\n\n" + codeBlock +
+			"\n\n" + sentences(5) + "\n\n## Effects\n\nShort section.\n",
+	}
+}
+
+func TestChunkChapterTokenLimit(t *testing.T) {
+	parser := NewParser(t.TempDir())
+	for _, strategy := range []string{ChunkByHeading, ChunkByWindow, ChunkBySentence} {
+		cfg := ChunkerConfig{Strategy: strategy, MaxTokens: 60, OverlapTokens: 15}
+		chunks := parser.ChunkChapter(chunkTestChapter(), cfg)
+		if len(chunks) < 3 {
+			t.Fatalf("%s: got %d chunks, want the long section split", strategy, len(chunks))
+		}
+		for _, c := range chunks {
+			if c.Tokens != EstimateTokens(c.Content) {
+				t.Errorf("%s: %q reports %d tokens, estimate is %d", strategy, c.Section, c.Tokens, EstimateTokens(c.Content))
+			}
+			// Only a chunk holding the code block may exceed the limit
+			if c.Tokens > cfg.MaxTokens && !strings.Contains(c.Content, "```") {
+				t.Errorf("%s: %q has %d tokens, limit is %d", strategy, c.Section, c.Tokens, cfg.MaxTokens)
+			}
+		}
+	}
+}
+
+func TestChunkChapterCodeFences(t *testing.T) {
+	parser := NewParser(t.TempDir())
+	for _, strategy := range []string{ChunkByHeading, ChunkByWindow, ChunkBySentence} {
+		chunks := parser.ChunkChapter(chunkTestChapter(), ChunkerConfig{Strategy: strategy, MaxTokens: 60, OverlapTokens: 15})
+
+		holding := 0
+		for _, c := range chunks {
+			if strings.Contains(c.Content, "## not a header") {
+				holding++
+				if !strings.Contains(c.Content, codeBlock) {
+					t.Errorf("%s: code block was cut:\n%s", strategy, c.Content)
+				}
+			}
+			if c.SectionID != "state" && c.SectionID != "effects" && c.SectionID != "" {
+				t.Errorf("%s: unexpected section %q from a header inside the code block", strategy, c.SectionID)
+			}
+			if strings.Count(c.Content, "```")%2 != 0 {
+				t.Errorf("%s: unbalanced fence in %q", strategy, c.Section)
+			}
+		}
+		if holding == 0 {
+			t.Errorf("%s: code block missing from the chunks", strategy)
+		}
+	}
+}
+
+func TestChunkChapterOverlap(t *testing.T) {
+	parser := NewParser(t.TempDir())
+	chapter := &Chapter{ID: "hooks", Name: "Hooks", Content: "## State\n\n" + sentences(30)}
+
+	for _, tt := range []struct {
+		strategy string
+		overlap  int
+	}{
+		{ChunkByWindow, 15},
+		{ChunkBySentence, 15},
+		{ChunkByWindow, 0},
+	} {
+		chunks := parser.ChunkChapter(chapter, ChunkerConfig{Strategy: tt.strategy, MaxTokens: 50, OverlapTokens: tt.overlap})
+		if len(chunks) < 2 {
+			t.Fatalf("%s: got %d chunks, want several", tt.strategy, len(chunks))
+		}
+		for i := 1; i < len(chunks); i++ {
+			shared := sharedTokens(chunks[i-1].Content, chunks[i].Content)
+			if tt.overlap == 0 && shared != 0 {
+				t.Errorf("%s: chunks %d and %d share %d tokens, want none", tt.strategy, i, i+1, shared)
+			}
+			if tt.overlap > 0 && (shared == 0 || shared > tt.overlap) {
+				t.Errorf("%s: chunks %d and %d share %d tokens, want 1-%d", tt.strategy, i, i+1, shared, tt.overlap)
+			}
+		}
+		if want := "Sentence number 30"; !strings.Contains(chunks[len(chunks)-1].Content, want) {
+			t.Errorf("%s: last chunk lost the end of the section", tt.strategy)
+		}
+	}
+
+	// The heading strategy never overlaps
+	chunks := parser.ChunkChapter(chapter, ChunkerConfig{Strategy: ChunkByHeading, MaxTokens: 50, OverlapTokens: 15})
+	for i := 1; i < len(chunks); i++ {
+		if shared := sharedTokens(chunks[i-1].Content, chunks[i].Content); shared != 0 {
+			t.Errorf("heading: chunks %d and %d share %d tokens", i, i+1, shared)
+		}
+	}
+}
+
+// sharedTokens returns the estimated tokens of the longest suffix of a that
+// starts b, matching whole words
+func sharedTokens(a, b string) int {
+	words := strings.Fields(a)
+	for i := range words {
+		suffix := strings.Join(words[i:], " ")
+		if strings.HasPrefix(b, suffix+" ") || b == suffix {
+			return EstimateTokens(suffix)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,55 @@
+package book
+
+import "strings"
+
+// Content types of a piece of chapter text
+const (
+	ContentProse = "prose"
+	ContentCode  = "code"
+)
+
+// ContentType classifies markdown as code when it holds a fenced code block
+// with at least one non-blank line, and as prose otherwise. A chunk mixing an
+// explanation with its example is code, so a "code" filter finds every example.
+func ContentType(markdown string) string {
+	var fence codeFence
+	for _, line := range strings.Split(markdown, "\n") {
+		if fence.toggle(line) {
+			continue
+		}
+		if fence.inside() && strings.TrimSpace(line) != "" {
+			return ContentCode
+		}
+	}
+	return ContentProse
+}
+
+// codeFence tracks whether lines are inside a fenced code block. A block
+// opened with ``` or ~~~ only closes with the same marker.
+type codeFence struct {
+	open string // marker of the open block, empty outside code
+}
+
+// toggle reports whether line opens or closes a block, updating the state
+func (f *codeFence) toggle(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	for _, marker := range []string{"```", "~~~"} {
+		if !strings.HasPrefix(trimmed, marker) {
+			continue
+		}
+		switch f.open {
+		case "":
+			f.open = marker
+			return true
+		case marker:
+			f.open = ""
+			return true
+		}
+	}
+	return false
+}
+
+// inside reports whether the last line seen was in a code block
+func (f *codeFence) inside() bool {
+	return f.open != ""
+}
@@ -0,0 +1,73 @@
+package book
+
+import "testing"
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{"prose", "## Hooks\n\nHooks let components keep state.", ContentProse},
+		{"only code", "```ts\nconst [count, setCount] = useState(0)\n```", ContentCode},
+		{"mostly prose with an example",
+			"Hooks let components keep state.\nThey run on every render.\nKeep them at the top level.\n\n```ts\nuseState(0)\n```",
+			ContentCode},
+		{"tilde fence", "Install it:\n\n~~~\nnpm i\n~~~", ContentCode},
+		{"part split inside a fence", "```go\nfunc main() {}", ContentCode},
+		{"empty fence", "Nothing to show:\n\n```\n\n```", ContentProse},
+		{"inline code", "Call `useState` with the initial value.", ContentProse},
+		{"empty", "", ContentProse},
+	}
+	for _, tt := range tests {
+		if got := ContentType(tt.markdown); got != tt.want {
+			t.Errorf("%s: ContentType = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCodeFence(t *testing.T) {
+	lines := []struct {
+		line   string
+		toggle bool
+		inside bool
+	}{
+		{"Prose", false, false},
+		{"~~~bash", true, true},
+		{"# comment, not a header", false, true},
+		{"```", false, true}, // a different marker does not close the block
+		{"~~~", true, false},
+		{"  ```ts", true, true},
+		{"~~~", false, true},
+		{"```", true, false},
+	}
+	var fence codeFence
+	for i, l := range lines {
+		if got := fence.toggle(l.line); got != l.toggle || fence.inside() != l.inside {
+			t.Errorf("line %d %q: toggle %v inside %v, want %v %v", i, l.line, got, fence.inside(), l.toggle, l.inside)
+		}
+	}
+}
+
+func TestTildeFences(t *testing.T) {
+	body := "## Setup\n\nInstall:\n\n~~~bash\n# not a header\n\nnpm i\n~~~\n\n## Usage\n\nRun it.\n"
+
+	parser := NewParser(t.TempDir())
+	var titles []string
+	for _, s := range parser.splitSections(body) {
+		titles = append(titles, s.title)
+	}
+	if len(titles) != 2 || titles[0] != "Setup" || titles[1] != "Usage" {
+		t.Errorf("sections = %q, want Setup and Usage", titles)
+	}
+
+	var code []string
+	for _, b := range splitBlocks(body) {
+		if b.code {
+			code = append(code, b.text)
+		}
+	}
+	if want := "~~~bash\n# not a header\n\nnpm i\n~~~\n\n"; len(code) != 1 || code[0] != want {
+		t.Errorf("code blocks = %q, want the whole ~~~ block", code)
+	}
+}
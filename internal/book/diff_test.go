@@ -0,0 +1,116 @@
+package book
+
+import (
+	"strings"
+	"testing"
+)
+
+// render writes ops one per line as "<kind><line>"
+func render(ops []diffOp) string {
+	lines := make([]string, len(ops))
+	for i, op := range ops {
+		lines[i] = string(op.kind) + op.line
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string   // lines separated by spaces
+		want []string // "<kind><line>"
+	}{
+		{"both empty", "", "", nil},
+		{"all added", "", "a b", []string{"+a", "+b"}},
+		{"all removed", "a b", "", []string{"-a", "-b"}},
+		{"equal", "a b c", "a b c", []string{" a", " b", " c"}},
+		{"insert in the middle", "a c", "a b c", []string{" a", "+b", " c"}},
+		{"delete in the middle", "a b c", "a c", []string{" a", "-b", " c"}},
+		{"replace one line", "a b c", "a x c", []string{" a", "-b", "+x", " c"}},
+		{"append", "a b", "a b c", []string{" a", " b", "+c"}},
+		{"prepend", "b c", "a b c", []string{"+a", " b", " c"}},
+		{"swap", "a b", "b a", []string{"-a", " b", "+a"}},
+		{"disjoint", "a b", "c d", []string{"-a", "-b", "+c", "+d"}},
+		{"repeated lines", "a a b a", "a b a a", []string{" a", "-a", " b", " a", "+a"}},
+		{"classic example", "a b c a b b a", "c b a b a c", []string{"-a", "-b", " c", "+b", " a", " b", "-b", " a", "+c"}},
+	}
+
+	for _, tt := range tests {
+		got := render(diffLines(strings.Fields(tt.a), strings.Fields(tt.b)))
+		if want := strings.Join(tt.want, "\n"); got != want {
+			t.Errorf("%s:\ngot\n%s\nwant\n%s", tt.name, got, want)
+		}
+	}
+}
+
+// TestDiffLinesIsMinimal checks that applying the ops rebuilds both sides and
+// that no shorter edit script exists, using the LCS length
+func TestDiffLinesIsMinimal(t *testing.T) {
+	pairs := [][2]string{
+		{"a b c d e f g", "a c d x f g y"},
+		{"x y z x y z", "z y x z y x"},
+		{"1 2 3 4 5 6 7 8 9", "9 8 7 6 5 4 3 2 1"},
+		{"p q p q p q", "q p q p"},
+	}
+	for _, pair := range pairs {
+		a, b := strings.Fields(pair[0]), strings.Fields(pair[1])
+		ops := diffLines(a, b)
+
+		var oldSide, newSide []string
+		edits := 0
+		for _, op := range ops {
+			if op.kind != '+' {
+				oldSide = append(oldSide, op.line)
+			}
+			if op.kind != '-' {
+				newSide = append(newSide, op.line)
+			}
+			if op.kind != ' ' {
+				edits++
+			}
+		}
+		if strings.Join(oldSide, " ") != pair[0] || strings.Join(newSide, " ") != pair[1] {
+			t.Errorf("%v: ops do not rebuild both sides", pair)
+		}
+		if want := len(a) + len(b) - 2*lcs(a, b); edits != want {
+			t.Errorf("%v: %d edits, want %d", pair, edits, want)
+		}
+	}
+}
+
+func lcs(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(cur[j], prev[j+1])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	var a, b []string
+	for i := 1; i <= 20; i++ {
+		line := strings.Repeat("x", i)
+		a = append(a, line)
+		if i == 2 {
+			line = "changed"
+		}
+		if i != 15 {
+			b = append(b, line)
+		}
+	}
+
+	got := unifiedDiff(diffLines(a, b), 1)
+	want := "@@ -1,3 +1,3 @@\n x\n-xx\n+changed\n xxx\n" +
+		"@@ -14,3 +14,2 @@\n " + strings.Repeat("x", 14) + "\n-" + strings.Repeat("x", 15) + "\n " + strings.Repeat("x", 16) + "\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
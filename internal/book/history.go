@@ -0,0 +1,236 @@
+package book
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Alan-TheGentleman/gentleman-book-mcp/internal/gitrepo"
+)
+
+// ChapterHistory lists the commits that changed a chapter's file, newest first
+func (p *Parser) ChapterHistory(chapterID string, locale string, limit int) ([]ChapterRevision, error) {
+	repo, relPath, err := p.chapterRepo(chapterID, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := repo.ResolveRevision("HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("error resolving HEAD: %w", err)
+	}
+
+	commits, err := repo.Log(head, relPath, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error reading history of %s: %w", relPath, err)
+	}
+
+	revisions := make([]ChapterRevision, 0, len(commits))
+	for _, c := range commits {
+		revisions = append(revisions, ChapterRevision{
+			Hash:    c.Hash.String(),
+			Short:   c.Hash.Short(),
+			Author:  c.Author.Name,
+			Email:   c.Author.Email,
+			Date:    c.Author.When,
+			Subject: c.Subject(),
+		})
+	}
+
+	return revisions, nil
+}
+
+// DiffChapter compares a chapter between two revisions, section by section.
+// A chapter missing at one revision compares as empty, so every section shows
+// up as added or removed.
+func (p *Parser) DiffChapter(chapterID string, locale string, from string, to string) (*ChapterDiff, error) {
+	repo, relPath, err := p.chapterRepo(chapterID, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	fromHash, err := repo.ResolveRevision(from)
+	if err != nil {
+		return nil, err
+	}
+	toHash, err := repo.ResolveRevision(to)
+	if err != nil {
+		return nil, err
+	}
+
+	oldFM, oldBody, err := p.readRevision(repo, fromHash, relPath)
+	if err != nil {
+		return nil, err
+	}
+	newFM, newBody, err := p.readRevision(repo, toHash, relPath)
+	if err != nil {
+		return nil, err
+	}
+	if oldFM == nil && newFM == nil {
+		return nil, fmt.Errorf("%s does not exist at %s or %s", relPath, fromHash.Short(), toHash.Short())
+	}
+	if oldFM == nil {
+		oldFM = &frontmatter{}
+	}
+	if newFM == nil {
+		newFM = &frontmatter{}
+	}
+
+	diff := &ChapterDiff{
+		ChapterID: chapterID,
+		Locale:    locale,
+		FilePath:  relPath,
+		From:      fromHash.String(),
+		To:        toHash.String(),
+		Metadata:  diffFrontmatter(oldFM, newFM),
+		Sections:  []SectionDiff{},
+	}
+
+	oldSections := sectionKeys(p.splitSections(oldBody))
+	newSections := sectionKeys(p.splitSections(newBody))
+
+	oldByKey := make(map[string]chapterSection)
+	for _, s := range oldSections {
+		oldByKey[s.key] = s.chapterSection
+	}
+	newByKey := make(map[string]bool)
+
+	// Walk the new version in order so sections keep their reading order
+	for _, s := range newSections {
+		newByKey[s.key] = true
+
+		old, existed := oldByKey[s.key]
+		if existed && old.content == s.content {
+			diff.Unchanged++
+			continue
+		}
+
+		status := "modified"
+		if !existed {
+			status = "added"
+		}
+		diff.Sections = append(diff.Sections, diffSection(s.title, s.tagID, status, old.content, s.content))
+	}
+
+	for _, s := range oldSections {
+		if !newByKey[s.key] {
+			diff.Sections = append(diff.Sections, diffSection(s.title, s.tagID, "removed", s.content, ""))
+		}
+	}
+
+	return diff, nil
+}
+
+// chapterRepo opens the repository containing a chapter and returns the
+// chapter's path relative to the repository root
+func (p *Parser) chapterRepo(chapterID string, locale string) (*gitrepo.Repository, string, error) {
+	chapter, err := p.GetChapter(chapterID, locale)
+	if err != nil {
+		return nil, "", err
+	}
+
+	repo, err := gitrepo.Open(chapter.FilePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("book is not in a git repository: %w", err)
+	}
+
+	relPath, err := repo.RelPath(chapter.FilePath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return repo, relPath, nil
+}
+
+// readRevision reads a chapter file at a commit and separates its
+// frontmatter. The frontmatter is nil when the file does not exist there.
+func (p *Parser) readRevision(repo *gitrepo.Repository, commit gitrepo.Hash, relPath string) (*frontmatter, string, error) {
+	content, err := repo.ReadFile(commit, relPath)
+	if errors.Is(err, gitrepo.ErrNotExist) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	fm, body, err := p.parseFrontmatter(string(content))
+	if err != nil {
+		return nil, "", fmt.Errorf("error parsing frontmatter at %s: %w", commit.Short(), err)
+	}
+	return fm, body, nil
+}
+
+// keyedSection is a section with a key that tells repeated headers apart
+type keyedSection struct {
+	chapterSection
+	key string
+}
+
+// sectionKeys pairs sections across revisions by tag ID and, for headers
+// that repeat, by occurrence. The tag IDs themselves stay the ones
+// GetSection resolves.
+func sectionKeys(sections []chapterSection) []keyedSection {
+	seen := make(map[string]int)
+	keyed := make([]keyedSection, len(sections))
+	for i, s := range sections {
+		keyed[i] = keyedSection{chapterSection: s, key: s.tagID + "#" + strconv.Itoa(seen[s.tagID])}
+		seen[s.tagID]++
+	}
+	return keyed
+}
+
+func diffSection(title, tagID, status, oldContent, newContent string) SectionDiff {
+	ops := diffLines(splitLines(oldContent), splitLines(newContent))
+
+	sd := SectionDiff{
+		Section: title,
+		TagID:   tagID,
+		Status:  status,
+		Diff:    unifiedDiff(ops, 3),
+	}
+	for _, op := range ops {
+		switch op.kind {
+		case '+':
+			sd.LinesAdded++
+		case '-':
+			sd.LinesRemoved++
+		}
+	}
+	return sd
+}
+
+func diffFrontmatter(oldFM, newFM *frontmatter) []MetadataChange {
+	var changes []MetadataChange
+
+	if oldFM.Name != newFM.Name {
+		changes = append(changes, MetadataChange{Field: "name", From: oldFM.Name, To: newFM.Name})
+	}
+	if oldFM.Order != newFM.Order {
+		changes = append(changes, MetadataChange{
+			Field: "order",
+			From:  strconv.Itoa(oldFM.Order),
+			To:    strconv.Itoa(newFM.Order),
+		})
+	}
+
+	if oldTags, newTags := strings.Join(oldFM.Tags, ", "), strings.Join(newFM.Tags, ", "); oldTags != newTags {
+		changes = append(changes, MetadataChange{Field: "tags", From: oldTags, To: newTags})
+	}
+
+	oldTitles, _ := json.Marshal(oldFM.TitleList)
+	newTitles, _ := json.Marshal(newFM.TitleList)
+	if string(oldTitles) != string(newTitles) {
+		changes = append(changes, MetadataChange{Field: "titleList", From: string(oldTitles), To: string(newTitles)})
+	}
+
+	return changes
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(content, "\n")
+}
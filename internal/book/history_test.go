@@ -0,0 +1,153 @@
+package book
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+const chapterV1 = `---
+id: 'patterns'
+order: 1
+name: 'Patterns'
+---
+
+Intro text.
+
+## Example
+
+First example.
+
+` + "```bash\n# not a header\necho hi\n```" + `
+
+## Notes
+
+Old notes.
+
+## Example
+
+Second example.
+`
+
+// chapterV2 edits the second "Example" and removes "Notes"
+const chapterV2 = `---
+id: 'patterns'
+order: 1
+name: 'Patterns'
+---
+
+Intro text.
+
+## Example
+
+First example.
+
+` + "```bash\n# not a header\necho hi\n```" + `
+
+## Example
+
+Second example, revised.
+`
+
+func TestDiffChapterSectionIDs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "es", "01.mdx")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Ada", "-c", "user.email=ada@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	for _, content := range []string{chapterV1, chapterV2} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "-A")
+		git("commit", "-q", "-m", "edit")
+	}
+
+	parser := NewParser(dir)
+	diff, err := parser.DiffChapter("patterns", "es", "HEAD~1", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The repeated header is paired by occurrence: only the second one changed
+	if diff.Unchanged != 2 || len(diff.Sections) != 2 {
+		t.Fatalf("got %d unchanged and sections %+v", diff.Unchanged, diff.Sections)
+	}
+	want := []struct{ tagID, status string }{{"example", "modified"}, {"notes", "removed"}}
+	for i, w := range want {
+		if s := diff.Sections[i]; s.TagID != w.tagID || s.Status != w.status {
+			t.Errorf("section %d = %s %s, want %s %s", i, s.TagID, s.Status, w.tagID, w.status)
+		}
+	}
+
+	// Every tag ID the diff reports is one GetSection resolves
+	if _, err := parser.GetSection("patterns", diff.Sections[0].TagID, "es"); err != nil {
+		t.Error(err)
+	}
+	content, err := parser.GetSection("patterns", "example", "es")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "## Example\n\nFirst example.\n\n```bash\n# not a header\necho hi\n```"; content != want {
+		t.Errorf("GetSection = %q, want the first section with its code block", content)
+	}
+}
+
+func TestDiffChapterAdded(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "es", "01.mdx")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Ada", "-c", "user.email=ada@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("commit", "-q", "--allow-empty", "-m", "release")
+	if err := os.WriteFile(path, []byte(chapterV2), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "-A")
+	git("commit", "-q", "-m", "add chapter")
+
+	parser := NewParser(dir)
+	for _, tt := range []struct{ from, to, status string }{
+		{"HEAD~1", "HEAD", "added"},
+		{"HEAD", "HEAD~1", "removed"},
+	} {
+		diff, err := parser.DiffChapter("patterns", "es", tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("%s..%s: %v", tt.from, tt.to, err)
+		}
+		// The introduction and both "Example" sections
+		if diff.Unchanged != 0 || len(diff.Sections) != 3 {
+			t.Fatalf("%s..%s: got %d unchanged and sections %+v", tt.from, tt.to, diff.Unchanged, diff.Sections)
+		}
+		for _, s := range diff.Sections {
+			if s.Status != tt.status {
+				t.Errorf("%s..%s: section %q is %s, want %s", tt.from, tt.to, s.TagID, s.Status, tt.status)
+			}
+		}
+	}
+}
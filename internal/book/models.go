@@ -1,5 +1,7 @@
 package book
 
+import "time"
+
 // Chapter represents a book chapter
 type Chapter struct {
 	ID        string    `json:"id"`
@@ -7,6 +9,7 @@ type Chapter struct {
 	Name      string    `json:"name"`
 	Locale    string    `json:"locale"`
 	TitleList []Section `json:"titleList"`
+	Tags      []string  `json:"tags,omitempty"`
 	Content   string    `json:"content"`
 	FilePath  string    `json:"filePath"`
 }
@@ -22,15 +25,64 @@ type SearchResult struct {
 	ChapterID   string  `json:"chapterId"`
 	ChapterName string  `json:"chapterName"`
 	Section     string  `json:"section"`
+	SectionID   string  `json:"sectionId,omitempty"`
 	Snippet     string  `json:"snippet"`
 	LineNumber  int     `json:"lineNumber"`
 	Relevance   float64 `json:"relevance"`
 	Locale      string  `json:"locale"`
 }
 
+// SectionRef points to a section of a chapter in a given locale
+type SectionRef struct {
+	ChapterID   string `json:"chapterId"`
+	ChapterName string `json:"chapterName"`
+	Locale      string `json:"locale"`
+	SectionID   string `json:"sectionId,omitempty"`
+	Section     string `json:"section,omitempty"`
+}
+
 // BookIndex represents the complete book index
 type BookIndex struct {
 	Locale        string    `json:"locale"`
 	TotalChapters int       `json:"totalChapters"`
 	Chapters      []Chapter `json:"chapters"`
 }
+
+// ChapterRevision represents a commit that changed a chapter file
+type ChapterRevision struct {
+	Hash    string    `json:"hash"`
+	Short   string    `json:"short"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+}
+
+// ChapterDiff represents the changes to a chapter between two revisions
+type ChapterDiff struct {
+	ChapterID string           `json:"chapterId"`
+	Locale    string           `json:"locale"`
+	FilePath  string           `json:"filePath"`
+	From      string           `json:"from"`
+	To        string           `json:"to"`
+	Metadata  []MetadataChange `json:"metadata,omitempty"`
+	Sections  []SectionDiff    `json:"sections"`
+	Unchanged int              `json:"unchangedSections"`
+}
+
+// MetadataChange represents a changed frontmatter field
+type MetadataChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// SectionDiff represents the changes to a single section of a chapter
+type SectionDiff struct {
+	Section      string `json:"section"`
+	TagID        string `json:"tagId"`
+	Status       string `json:"status"` // added, removed or modified
+	LinesAdded   int    `json:"linesAdded"`
+	LinesRemoved int    `json:"linesRemoved"`
+	Diff         string `json:"diff"`
+}
@@ -28,6 +28,7 @@ type frontmatter struct {
 	Order     int       `json:"order"`
 	Name      string    `json:"name"`
 	TitleList []Section `json:"titleList"`
+	Tags      []string  `json:"tags"`
 }
 
 // ParseChapter parses an MDX file and returns a Chapter
@@ -51,6 +52,7 @@ func (p *Parser) ParseChapter(filePath string, locale string) (*Chapter, error)
 		Name:      fm.Name,
 		Locale:    locale,
 		TitleList: fm.TitleList,
+		Tags:      fm.Tags,
 		Content:   body,
 		FilePath:  filePath,
 	}, nil
@@ -139,9 +141,36 @@ func (p *Parser) parseFrontmatter(content string) (*frontmatter, string, error)
 		}
 	}
 
+	fm.Tags = parseTags(fmContent)
+
 	return fm, body, nil
 }
 
+var (
+	tagsInlineRegex = regexp.MustCompile(`(?m)^tags:[ \t]*\[([^\]]*)\]`)
+	tagsListRegex   = regexp.MustCompile(`(?m)^tags:[ \t]*\n((?:[ \t]*-[ \t]*.+\n?)+)`)
+)
+
+// parseTags extracts tags written inline (tags: ['a', 'b']) or as a YAML list
+func parseTags(fmContent string) []string {
+	var items []string
+	if match := tagsInlineRegex.FindStringSubmatch(fmContent); match != nil {
+		items = strings.Split(match[1], ",")
+	} else if match := tagsListRegex.FindStringSubmatch(fmContent); match != nil {
+		for _, line := range strings.Split(match[1], "\n") {
+			items = append(items, strings.TrimPrefix(strings.TrimSpace(line), "-"))
+		}
+	}
+
+	var tags []string
+	for _, item := range items {
+		if tag := strings.Trim(strings.TrimSpace(item), `'"`); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
 // cleanArrayToJSON cleans YAML-like array to valid JSON
 func (p *Parser) cleanArrayToJSON(content string) string {
 	// Replace single quotes with double quotes
@@ -208,48 +237,69 @@ func (p *Parser) GetChapter(chapterID string, locale string) (*Chapter, error) {
 	return nil, fmt.Errorf("chapter not found: %s", chapterID)
 }
 
-// GetSection gets a specific section from a chapter
+// GetSection gets a specific section from a chapter. When a header repeats,
+// the first section with that tag ID is returned.
 func (p *Parser) GetSection(chapterID string, sectionTagID string, locale string) (string, error) {
 	chapter, err := p.GetChapter(chapterID, locale)
 	if err != nil {
 		return "", err
 	}
 
-	// Search for the section in content
-	lines := strings.Split(chapter.Content, "\n")
-
-	// Find the header that matches the tagId
-	inSection := false
-	var sectionContent strings.Builder
-	headerPattern := regexp.MustCompile(`^#{1,6}\s+(.+)$`)
-
-	for _, line := range lines {
-		if matches := headerPattern.FindStringSubmatch(line); len(matches) > 1 {
-			headerText := matches[1]
-			currentTagID := p.generateTagID(headerText)
-
-			if currentTagID == sectionTagID {
-				inSection = true
-				sectionContent.WriteString(line)
-				sectionContent.WriteString("\n")
-				continue
-			} else if inSection {
-				// Reached another section, stop
-				break
-			}
+	for _, section := range p.splitSections(chapter.Content) {
+		if section.level > 0 && section.tagID == sectionTagID {
+			return section.content, nil
 		}
+	}
+
+	return "", fmt.Errorf("section not found: %s", sectionTagID)
+}
+
+// chapterSection is a section of a chapter body delimited by markdown headers
+type chapterSection struct {
+	title   string
+	tagID   string
+	level   int // number of # in the header, 0 for the introduction
+	content string
+}
 
-		if inSection {
-			sectionContent.WriteString(line)
-			sectionContent.WriteString("\n")
+var sectionHeaderPattern = regexp.MustCompile(`^#{1,6}\s+(.+)$`)
+
+// splitSections splits a chapter body at its markdown headers, ignoring
+// headers inside code blocks. Content before the first header is returned
+// as an "Introduction" section. Every section ends at the next header of
+// any level, and repeated headers share the same tag ID.
+func (p *Parser) splitSections(body string) []chapterSection {
+	var sections []chapterSection
+	current := chapterSection{title: "Introduction", tagID: ""}
+	var content strings.Builder
+	var fence codeFence
+
+	flush := func() {
+		current.content = strings.TrimSpace(content.String())
+		if current.tagID != "" || current.content != "" {
+			sections = append(sections, current)
 		}
+		content.Reset()
 	}
 
-	if sectionContent.Len() == 0 {
-		return "", fmt.Errorf("section not found: %s", sectionTagID)
+	for _, line := range strings.Split(body, "\n") {
+		fence.toggle(line)
+
+		if matches := sectionHeaderPattern.FindStringSubmatch(line); !fence.inside() && len(matches) > 1 {
+			flush()
+			current = chapterSection{
+				title: strings.TrimSpace(matches[1]),
+				tagID: p.generateTagID(matches[1]),
+				level: len(line) - len(strings.TrimLeft(line, "#")),
+			}
+		}
+
+		content.WriteString(line)
+		content.WriteString("\n")
 	}
+	flush()
 
-	return strings.TrimSpace(sectionContent.String()), nil
+	return sections
 }
 
 // generateTagID generates a tagId from a title
@@ -286,7 +336,7 @@ func (p *Parser) Search(query string, locale string) ([]SearchResult, error) {
 	for _, chapter := range chapters {
 		scanner := bufio.NewScanner(strings.NewReader(chapter.Content))
 		lineNum := 0
-		currentSection := ""
+		currentSection, sectionID := "", ""
 		headerPattern := regexp.MustCompile(`^#{1,6}\s+(.+)$`)
 
 		for scanner.Scan() {
@@ -297,6 +347,7 @@ func (p *Parser) Search(query string, locale string) ([]SearchResult, error) {
 			// Update current section
 			if matches := headerPattern.FindStringSubmatch(line); len(matches) > 1 {
 				currentSection = matches[1]
+				sectionID = p.generateTagID(matches[1])
 			}
 
 			// Search for matches
@@ -320,6 +371,7 @@ func (p *Parser) Search(query string, locale string) ([]SearchResult, error) {
 					ChapterID:   chapter.ID,
 					ChapterName: chapter.Name,
 					Section:     currentSection,
+					SectionID:   sectionID,
 					Snippet:     snippet,
 					LineNumber:  lineNum,
 					Relevance:   relevance,
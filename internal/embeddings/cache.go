@@ -0,0 +1,268 @@
+package embeddings
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================
+// QUERY EMBEDDING CACHE
+// ============================================
+
+// queryCacheSaveDelay batches the writes of a persisted cache: it is saved
+// once no query has been added for this long
+const queryCacheSaveDelay = 5 * time.Second
+
+// QueryCacheConfig controls the cache of query embeddings
+type QueryCacheConfig struct {
+	Size int           // maximum entries, 0 disables the cache
+	TTL  time.Duration // how long an entry stays valid, 0 for no expiry
+	Path string        // file the cache is persisted to, empty keeps it in memory
+}
+
+// DefaultQueryCacheConfig keeps 500 queries in memory for 24 hours
+func DefaultQueryCacheConfig() QueryCacheConfig {
+	return QueryCacheConfig{Size: 500, TTL: 24 * time.Hour}
+}
+
+// QueryCacheConfigFromEnv reads QUERY_CACHE_SIZE, QUERY_CACHE_TTL and
+// QUERY_CACHE_PATH over the defaults
+func QueryCacheConfigFromEnv() QueryCacheConfig {
+	cfg := DefaultQueryCacheConfig()
+	cfg.Size = max(0, envInt("QUERY_CACHE_SIZE", cfg.Size))
+	if ttl, err := time.ParseDuration(os.Getenv("QUERY_CACHE_TTL")); err == nil && ttl >= 0 {
+		cfg.TTL = ttl
+	}
+	cfg.Path = os.Getenv("QUERY_CACHE_PATH")
+	return cfg
+}
+
+// QueryCacheStats reports how well the cache is doing
+type QueryCacheStats struct {
+	Enabled  bool    `json:"enabled"`
+	Entries  int     `json:"entries"`
+	Capacity int     `json:"capacity"`
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRate  float64 `json:"hitRate"` // hits / (hits + misses), 0 before any lookup
+}
+
+// queryCache is an LRU cache of query vectors keyed by provider, model and
+// normalized query text
+type queryCache struct {
+	config QueryCacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	hits    int64
+	misses  int64
+	save    *time.Timer
+}
+
+// queryCacheEntry is both the list element value and the persisted record
+type queryCacheEntry struct {
+	Key     string    `json:"key"`
+	Vector  Vector    `json:"vector"`
+	Created time.Time `json:"created"`
+}
+
+// newQueryCache creates a cache, loading the persisted entries if any.
+// It returns nil when the cache is disabled.
+func newQueryCache(cfg QueryCacheConfig) *queryCache {
+	if cfg.Size <= 0 {
+		return nil
+	}
+
+	c := &queryCache{
+		config:  cfg,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+	if cfg.Path != "" {
+		if err := c.load(); err != nil && !os.IsNotExist(err) {
+			log.Printf("Ignoring query cache at %s: %v", cfg.Path, err)
+		}
+	}
+	return c
+}
+
+// queryCacheKey identifies a query in one embedding space: the provider, the
+// server it runs on and the model. Case and whitespace differences map to the
+// same entry.
+func queryCacheKey(provider Provider, baseURL, model, query string) string {
+	return string(provider) + "\x00" + baseURL + "\x00" + model + "\x00" + strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// get returns the cached vector for key, if present and not expired
+func (c *queryCache) get(key string) (Vector, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*queryCacheEntry)
+		if !c.expired(entry) {
+			c.order.MoveToFront(elem)
+			c.hits++
+			return entry.Vector, true
+		}
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+	c.misses++
+	return Vector{}, false
+}
+
+// put stores a vector, evicting the least recently used entry when full
+func (c *queryCache) put(key string, vector Vector) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.add(&queryCacheEntry{Key: key, Vector: vector, Created: time.Now()})
+	c.scheduleSave()
+}
+
+func (c *queryCache) add(entry *queryCacheEntry) {
+	if elem, ok := c.entries[entry.Key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[entry.Key] = c.order.PushFront(entry)
+	for c.order.Len() > c.config.Size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*queryCacheEntry).Key)
+	}
+}
+
+func (c *queryCache) expired(entry *queryCacheEntry) bool {
+	return c.config.TTL > 0 && time.Since(entry.Created) > c.config.TTL
+}
+
+// stats returns the cache counters; a nil cache reports itself disabled
+func (c *queryCache) stats() QueryCacheStats {
+	if c == nil {
+		return QueryCacheStats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := QueryCacheStats{
+		Enabled:  true,
+		Entries:  c.order.Len(),
+		Capacity: c.config.Size,
+		Hits:     c.hits,
+		Misses:   c.misses,
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRate = float64(c.hits) / float64(total)
+	}
+	return stats
+}
+
+// scheduleSave writes the cache to disk shortly after the last change.
+// Must be called with c.mu held.
+func (c *queryCache) scheduleSave() {
+	if c.config.Path == "" {
+		return
+	}
+	if c.save != nil {
+		c.save.Stop()
+	}
+	c.save = time.AfterFunc(queryCacheSaveDelay, func() {
+		if err := c.persist(); err != nil {
+			log.Printf("Error saving query cache: %v", err)
+		}
+	})
+}
+
+// flush saves the pending changes right away instead of waiting for the
+// save delay. A nil or in-memory cache has nothing to flush.
+func (c *queryCache) flush() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	pending := c.save != nil && c.save.Stop()
+	c.save = nil
+	c.mu.Unlock()
+
+	if !pending {
+		return nil
+	}
+	return c.persist()
+}
+
+// persist writes the live entries, most recent first, replacing the previous
+// file atomically
+func (c *queryCache) persist() error {
+	c.mu.Lock()
+	entries := make([]*queryCacheEntry, 0, c.order.Len())
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		if entry := elem.Value.(*queryCacheEntry); !c.expired(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("error encoding query cache: %w", err)
+	}
+
+	dir := filepath.Dir(c.config.Path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating query cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(c.config.Path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating query cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing query cache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing query cache: %w", err)
+	}
+	return os.Rename(tmp.Name(), c.config.Path)
+}
+
+// load reads the persisted entries, skipping expired ones
+func (c *queryCache) load() error {
+	data, err := os.ReadFile(c.config.Path)
+	if err != nil {
+		return err
+	}
+
+	var entries []*queryCacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("invalid query cache: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Entries are stored most recent first; add the oldest first so the
+	// recency order is restored
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !c.expired(entries[i]) && entries[i].Vector.Len() > 0 {
+			c.add(entries[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package embeddings
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// cachedKeys returns the keys of a cache, most recently used first
+func cachedKeys(c *queryCache) []string {
+	var keys []string
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		keys = append(keys, elem.Value.(*queryCacheEntry).Key)
+	}
+	return keys
+}
+
+func TestQueryCacheLRU(t *testing.T) {
+	cache := newQueryCache(QueryCacheConfig{Size: 2})
+	cache.put("a", NewVector([]float64{1, 0}))
+	cache.put("b", NewVector([]float64{0, 1}))
+
+	// Using a makes b the least recently used, so c evicts b
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("a missing")
+	}
+	cache.put("c", NewVector([]float64{1, 1}))
+
+	if _, ok := cache.get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if got, want := cachedKeys(cache), []string{"c", "a"}; !slices.Equal(got, want) {
+		t.Errorf("cache holds %v, want %v", got, want)
+	}
+
+	stats := cache.stats()
+	if stats.Entries != 2 || stats.Capacity != 2 || stats.Hits != 1 || stats.Misses != 1 || stats.HitRate != 0.5 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	if newQueryCache(QueryCacheConfig{Size: 0}) != nil {
+		t.Error("a zero size should disable the cache")
+	}
+}
+
+func TestQueryCacheTTL(t *testing.T) {
+	cache := newQueryCache(QueryCacheConfig{Size: 10, TTL: time.Hour})
+	cache.add(&queryCacheEntry{Key: "old", Vector: NewVector([]float64{1}), Created: time.Now().Add(-2 * time.Hour)})
+	cache.put("fresh", NewVector([]float64{1}))
+
+	if _, ok := cache.get("old"); ok {
+		t.Error("expired entry returned")
+	}
+	if _, ok := cache.get("fresh"); !ok {
+		t.Error("fresh entry missing")
+	}
+	if got := cachedKeys(cache); !slices.Equal(got, []string{"fresh"}) {
+		t.Errorf("cache holds %v, want the expired entry dropped", got)
+	}
+
+	// Without a TTL entries never expire
+	forever := newQueryCache(QueryCacheConfig{Size: 10})
+	forever.add(&queryCacheEntry{Key: "old", Vector: NewVector([]float64{1}), Created: time.Now().AddDate(-1, 0, 0)})
+	if _, ok := forever.get("old"); !ok {
+		t.Error("entry expired without a TTL")
+	}
+}
+
+func TestQueryCachePersistence(t *testing.T) {
+	cfg := QueryCacheConfig{Size: 10, TTL: time.Hour, Path: filepath.Join(t.TempDir(), "cache", "queries.json")}
+	cache := newQueryCache(cfg)
+
+	cache.add(&queryCacheEntry{Key: "expired", Vector: NewVector([]float64{1, 1}), Created: time.Now().Add(-2 * time.Hour)})
+	cache.put("a", NewVector([]float64{1, 0}))
+	cache.put("b", NewVector([]float64{0, 1}))
+	cache.get("a")
+
+	// Nothing is written until the save delay passes or the cache is flushed
+	if _, err := os.Stat(cfg.Path); !os.IsNotExist(err) {
+		t.Fatalf("cache saved before the delay: %v", err)
+	}
+	if err := cache.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if cache.save != nil {
+		t.Error("flush left the save timer scheduled")
+	}
+
+	loaded := newQueryCache(cfg)
+	if got, want := cachedKeys(loaded), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("loaded %v, want %v in recency order without the expired entry", got, want)
+	}
+	vector, ok := loaded.get("b")
+	if !ok || vector.Dot(NewVector([]float64{0, 1})) < 0.999 {
+		t.Errorf("loaded vector = %v, %v", vector, ok)
+	}
+
+	// A second flush with nothing pending leaves the file alone
+	if err := os.Remove(cfg.Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cfg.Path); !os.IsNotExist(err) {
+		t.Errorf("flush without changes wrote the cache: %v", err)
+	}
+
+	// A corrupt file is ignored
+	if err := os.WriteFile(cfg.Path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := cachedKeys(newQueryCache(cfg)); len(got) != 0 {
+		t.Errorf("loaded %v from a corrupt file", got)
+	}
+
+	var disabled *queryCache
+	if err := disabled.flush(); err != nil {
+		t.Errorf("nil cache flush: %v", err)
+	}
+}
+
+func TestSemanticEngineCloseSavesQueryCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.json")
+	engine := newSemanticEngine(NewLocalClient(64), ProviderLocal, "local")
+	engine.queryCache = newQueryCache(QueryCacheConfig{Size: 10, Path: path})
+
+	if _, err := engine.embedQuery(t.Context(), "react hooks"); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := newQueryCache(QueryCacheConfig{Size: 10, Path: path})
+	if _, ok := reloaded.get(queryCacheKey(ProviderLocal, "", "local", "React   Hooks")); !ok {
+		t.Error("query embedded before Close was not saved")
+	}
+}
+
+func TestQueryCacheKey(t *testing.T) {
+	key := queryCacheKey(ProviderOpenAICompatible, "http://a:8080/v1", "nomic", "React hooks")
+	if other := queryCacheKey(ProviderOpenAICompatible, "http://a:8080/v1", "nomic", "  react   HOOKS "); other != key {
+		t.Error("case and spacing should not change the key")
+	}
+	for _, other := range []string{
+		queryCacheKey(ProviderOpenAICompatible, "http://b:8080/v1", "nomic", "React hooks"),
+		queryCacheKey(ProviderOpenAICompatible, "http://a:8080/v1", "bge-m3", "React hooks"),
+		queryCacheKey(ProviderOllama, "http://a:8080/v1", "nomic", "React hooks"),
+	} {
+		if other == key {
+			t.Errorf("servers, models and providers must not share entries: %q", other)
+		}
+	}
+}
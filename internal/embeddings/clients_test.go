@@ -0,0 +1,246 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Alan-TheGentleman/gentleman-book-mcp/internal/fakeembed"
+)
+
+// fastRetries keeps retry tests quick
+var fastRetries = retryPolicy{maxRetries: 3, baseDelay: time.Millisecond, maxDelay: 5 * time.Millisecond}
+
+func newFakeOpenAI(t *testing.T, cfg fakeembed.Config) (*fakeembed.Server, *OpenAIClient) {
+	t.Helper()
+	fake, srv := fakeembed.NewTestServer(cfg)
+	t.Cleanup(srv.Close)
+
+	client, err := NewOpenAICompatibleClient(OpenAICompatibleConfig{BaseURL: srv.URL + "/v1", Model: "fake"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetLimits(OpenAILimits{MaxRetries: 3})
+	client.retry = fastRetries
+	return fake, client
+}
+
+func newFakeOllama(t *testing.T, cfg fakeembed.Config) (*fakeembed.Server, *OllamaClient) {
+	t.Helper()
+	fake, srv := fakeembed.NewTestServer(cfg)
+	t.Cleanup(srv.Close)
+
+	client := NewOllamaClient(srv.URL, "fake")
+	client.retry = fastRetries
+	return fake, client
+}
+
+func TestOpenAIClientEmbedBatch(t *testing.T) {
+	fake, client := newFakeOpenAI(t, fakeembed.Config{Dimensions: 32})
+
+	texts := []string{"ports and adapters", "react hooks", "ports and adapters"}
+	embeddings, err := client.EmbedBatch(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embeddings) != len(texts) {
+		t.Fatalf("got %d embeddings for %d texts", len(embeddings), len(texts))
+	}
+	for i, text := range texts {
+		want := fakeembed.Vector(text, 32)
+		if len(embeddings[i]) != 32 || embeddings[i][0] != want[0] || embeddings[i][31] != want[31] {
+			t.Fatalf("embedding %d does not match the fake's vector for %q", i, text)
+		}
+	}
+	if fake.Requests() != 1 {
+		t.Errorf("got %d requests, want 1", fake.Requests())
+	}
+}
+
+func TestOpenAIClientSplitsBatchesByTokens(t *testing.T) {
+	fake, client := newFakeOpenAI(t, fakeembed.Config{})
+	client.batchTokens = 10
+
+	texts := []string{strings.Repeat("a", 20), strings.Repeat("b", 20), strings.Repeat("c", 60), "d"}
+	embeddings, err := client.EmbedBatch(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embeddings) != len(texts) || fake.Requests() != 3 || fake.Inputs() != len(texts) {
+		t.Errorf("got %d embeddings in %d requests, want %d in 3", len(embeddings), fake.Requests(), len(texts))
+	}
+}
+
+func TestOpenAIClientRetriesRateLimit(t *testing.T) {
+	fake, client := newFakeOpenAI(t, fakeembed.Config{RetryAfter: 20 * time.Millisecond})
+	fake.FailNext(fakeembed.FaultRateLimit, 2)
+
+	start := time.Now()
+	if _, err := client.Embed(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.Requests() != 3 {
+		t.Errorf("got %d requests, want 3", fake.Requests())
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("retried after %v, want Retry-After honored (>= 40ms)", elapsed)
+	}
+}
+
+func TestOpenAIClientErrors(t *testing.T) {
+	fake, client := newFakeOpenAI(t, fakeembed.Config{})
+
+	// Server errors are retried until the retries run out
+	fake.FailNext(fakeembed.FaultServerError, 10)
+	_, err := client.Embed(context.Background(), "hello")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("got %v, want an HTTP 500 APIError", err)
+	}
+	if fake.Requests() != 4 {
+		t.Errorf("got %d requests, want 4 (1 + 3 retries)", fake.Requests())
+	}
+
+	// A malformed body is not retried
+	fake, client = newFakeOpenAI(t, fakeembed.Config{})
+	fake.FailNext(fakeembed.FaultMalformed, 1)
+	if _, err := client.Embed(context.Background(), "hello"); err == nil {
+		t.Fatal("malformed response accepted")
+	}
+	if fake.Requests() != 1 {
+		t.Errorf("got %d requests, want 1", fake.Requests())
+	}
+}
+
+func TestOllamaClient(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		fake, client := newFakeOllama(t, fakeembed.Config{Dimensions: 16, LegacyOllama: legacy})
+
+		texts := []string{"ports and adapters", "react hooks", "unit tests"}
+		embeddings, err := client.EmbedBatch(context.Background(), texts)
+		if err != nil {
+			t.Fatalf("legacy=%v: %v", legacy, err)
+		}
+		for i, text := range texts {
+			if want := fakeembed.Vector(text, 16); len(embeddings[i]) != 16 || embeddings[i][3] != want[3] {
+				t.Fatalf("legacy=%v: embedding %d does not match the fake's vector", legacy, i)
+			}
+		}
+		if fake.Inputs() != len(texts) {
+			t.Errorf("legacy=%v: fake embedded %d texts, want %d", legacy, fake.Inputs(), len(texts))
+		}
+		if client.legacyOnly.Load() != legacy {
+			t.Errorf("legacy=%v: client legacyOnly = %v", legacy, client.legacyOnly.Load())
+		}
+	}
+}
+
+func TestOllamaClientRetries(t *testing.T) {
+	fake, client := newFakeOllama(t, fakeembed.Config{})
+	fake.FailNext(fakeembed.FaultServerError, 1)
+	fake.FailNext(fakeembed.FaultRateLimit, 1)
+
+	if _, err := client.Embed(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.Requests() != 3 {
+		t.Errorf("got %d requests, want 3", fake.Requests())
+	}
+}
+
+// TestOllamaBatchFallback checks that a batch that keeps failing costs its
+// retries plus a single attempt per text, not retries for every text
+func TestOllamaBatchFallback(t *testing.T) {
+	fake, client := newFakeOllama(t, fakeembed.Config{})
+	texts := []string{"ports", "adapters", "hooks"}
+	batchAttempts := 1 + fastRetries.maxRetries
+
+	fake.FailNext(fakeembed.FaultServerError, batchAttempts+len(texts))
+	_, err := client.EmbedBatch(context.Background(), texts)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failures) != len(texts) {
+		t.Fatalf("got %v, want every text listed as failed", err)
+	}
+	if want := batchAttempts + len(texts); fake.Requests() != want {
+		t.Errorf("got %d requests, want %d", fake.Requests(), want)
+	}
+
+	// Texts that embed on their own are kept
+	fake.FailNext(fakeembed.FaultServerError, batchAttempts+1)
+	embeddings, err := client.EmbedBatch(context.Background(), texts)
+	if !errors.As(err, &batchErr) || len(batchErr.Failures) != 1 {
+		t.Fatalf("got %v, want one failed text", err)
+	}
+	embedded := 0
+	for _, e := range embeddings {
+		if e != nil {
+			embedded++
+		}
+	}
+	if embedded != len(texts)-1 {
+		t.Errorf("got %d embeddings, want %d", embedded, len(texts)-1)
+	}
+}
+
+func TestSemanticEngineWithFake(t *testing.T) {
+	t.Setenv("QUERY_CACHE_SIZE", "10")
+	fake, client := newFakeOpenAI(t, fakeembed.Config{Dimensions: 128})
+	engine := newSemanticEngine(client, ProviderOpenAICompatible, client.Model())
+
+	var chunks []Chunk
+	for i, content := range []string{
+		"ports and adapters isolate the domain",
+		"react hooks manage component state",
+		"unit tests replace collaborators with mocks",
+	} {
+		chunk := Chunk{ChapterID: string(rune('a' + i)), Section: "Intro", Content: content, Locale: "en"}
+		chunk.ID = ChunkID("en", chunk.ChapterID, chunk.Section, chunk.EmbedText())
+		chunks = append(chunks, chunk)
+	}
+
+	ctx := context.Background()
+	stats, err := engine.IndexChunks(ctx, chunks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Added != len(chunks) || engine.IndexInfo().Dimensions != 128 {
+		t.Fatalf("got %+v and info %v", stats, engine.IndexInfo())
+	}
+
+	for range 2 {
+		results, err := engine.Search(ctx, "React hooks", SearchOptions{TopK: 1, Locale: "en"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].ChapterID != "b" {
+			t.Fatalf("got %+v, want chapter b first", results)
+		}
+	}
+
+	// The repeated query comes from the cache
+	if cache := engine.QueryCacheStats(); cache.Hits != 1 || cache.Misses != 1 {
+		t.Errorf("query cache %+v, want 1 hit and 1 miss", cache)
+	}
+	if fake.Inputs() != len(chunks)+1 {
+		t.Errorf("fake embedded %d texts, want %d", fake.Inputs(), len(chunks)+1)
+	}
+	status := engine.Status()
+	if status.BaseURL != client.BaseURL() || status.Dimensions != 128 || status.Locales["en"] != len(chunks) {
+		t.Errorf("status %+v does not describe the engine and its index", status)
+	}
+	if status.Queries != 2 || status.LastBuildAt == nil || status.LastBuildDuration == "" || status.LastError != "" {
+		t.Errorf("status %+v, want 2 queries, a build and no error", status)
+	}
+
+	// A failed query is reported as the last error and not averaged in
+	fake.FailNext(fakeembed.FaultServerError, 4)
+	if _, err := engine.Search(ctx, "unit tests", SearchOptions{TopK: 1, Locale: "en"}); err == nil {
+		t.Fatal("expected the search to fail")
+	}
+	if status := engine.Status(); status.LastError == "" || status.Queries != 2 {
+		t.Errorf("status %+v, want the failure as last error and still 2 queries", status)
+	}
+}
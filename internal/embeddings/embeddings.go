@@ -3,22 +3,33 @@ package embeddings
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"math"
+	"log"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/Alan-TheGentleman/gentleman-book-mcp/internal/book"
 )
 
 // Provider defines the embeddings provider type
 type Provider string
 
 const (
-	ProviderOpenAI Provider = "openai"
-	ProviderOllama Provider = "ollama"
+	ProviderOpenAI           Provider = "openai"
+	ProviderOllama           Provider = "ollama"
+	ProviderOpenAICompatible Provider = "openai-compatible"
+	ProviderLocal            Provider = "local"
 )
 
 // EmbeddingClient is the interface for generating embeddings
@@ -29,13 +40,86 @@ type EmbeddingClient interface {
 
 // Chunk represents a text fragment with its embedding
 type Chunk struct {
-	ID          string    `json:"id"`
-	ChapterID   string    `json:"chapterId"`
-	ChapterName string    `json:"chapterName"`
-	Section     string    `json:"section"`
-	Content     string    `json:"content"`
-	Embedding   []float64 `json:"embedding"`
-	Locale      string    `json:"locale"`
+	ID          string `json:"id"`
+	ChapterID   string `json:"chapterId"`
+	ChapterName string `json:"chapterName"`
+	Section     string `json:"section"`
+	SectionID   string `json:"sectionId,omitempty"`
+	Breadcrumb  string `json:"breadcrumb,omitempty"` // heading context embedded along with the content
+	Content     string `json:"content"`
+	Embedding   Vector `json:"embedding"`
+	Locale      string `json:"locale"`
+
+	// Metadata used by search filters
+	ChapterOrder int      `json:"chapterOrder,omitempty"`
+	ContentType  string   `json:"contentType,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+}
+
+// ChunkID derives a stable chunk ID from the chunk's source and the text that
+// gets embedded (see EmbedText), so unchanged text keeps its ID across builds
+func ChunkID(locale, chapterID, section, content string) string {
+	sum := sha256.Sum256([]byte(locale + "\x00" + chapterID + "\x00" + section + "\x00" + content))
+	return hex.EncodeToString(sum[:16])
+}
+
+// EmbedText returns the text sent to the embeddings API: the breadcrumb, if
+// any, followed by the content
+func (c *Chunk) EmbedText() string {
+	if c.Breadcrumb == "" {
+		return c.Content
+	}
+	return c.Breadcrumb + "\n\n" + c.Content
+}
+
+// sourceKey identifies the section a chunk comes from, independently of its
+// content and of how many parts the section was split into
+func (c *Chunk) sourceKey() string {
+	return c.Locale + "\x00" + c.ChapterID + "\x00" + c.SectionID
+}
+
+// IndexStats reports what an index build changed
+type IndexStats struct {
+	Added     int            `json:"added"`
+	Updated   int            `json:"updated"`
+	Removed   int            `json:"removed"`
+	Unchanged int            `json:"unchanged"`
+	Failed    int            `json:"failed"`
+	Failures  []ChunkFailure `json:"failures,omitempty"`
+}
+
+// ChunkFailure describes a chunk that could not be embedded
+type ChunkFailure struct {
+	ChunkID   string `json:"chunkId"`
+	ChapterID string `json:"chapterId"`
+	Section   string `json:"section"`
+	Locale    string `json:"locale"`
+	Error     string `json:"error"`
+}
+
+// IndexInfo identifies the embedding space an index was built in. Vectors
+// from different providers, models or sizes are not comparable.
+type IndexInfo struct {
+	Provider   Provider `json:"provider"`
+	Model      string   `json:"model"`
+	Dimensions int      `json:"dimensions"`
+}
+
+func (i IndexInfo) String() string {
+	if i.Dimensions == 0 {
+		return fmt.Sprintf("%s/%s", i.Provider, i.Model)
+	}
+	return fmt.Sprintf("%s/%s (%d dims)", i.Provider, i.Model, i.Dimensions)
+}
+
+// MismatchError means the index and the current embeddings are not comparable
+type MismatchError struct {
+	Index   IndexInfo
+	Current IndexInfo
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("index built with %s, current provider is %s — rebuild required", e.Index, e.Current)
 }
 
 // SemanticResult represents a semantic search result
@@ -43,21 +127,34 @@ type SemanticResult struct {
 	ChapterID   string  `json:"chapterId"`
 	ChapterName string  `json:"chapterName"`
 	Section     string  `json:"section"`
+	SectionID   string  `json:"sectionId,omitempty"`
+	Breadcrumb  string  `json:"breadcrumb,omitempty"`
 	Content     string  `json:"content"`
 	Score       float64 `json:"score"`
 	Locale      string  `json:"locale"`
 }
 
-// VectorStore stores and searches chunks by similarity
+// VectorStore stores chunks and searches them by similarity through a VectorIndex
 type VectorStore struct {
-	chunks []Chunk
-	mu     sync.RWMutex
+	chunks  []Chunk
+	locales map[string]int // chunk count per locale
+	config  IndexConfig
+	index   VectorIndex
+	mu      sync.RWMutex
 }
 
-// NewVectorStore creates a new vector store
+// NewVectorStore creates a new vector store with an exact flat index
 func NewVectorStore() *VectorStore {
+	return NewVectorStoreWithIndex(DefaultIndexConfig())
+}
+
+// NewVectorStoreWithIndex creates a new vector store using the given index
+func NewVectorStoreWithIndex(cfg IndexConfig) *VectorStore {
 	return &VectorStore{
-		chunks: make([]Chunk, 0),
+		chunks:  make([]Chunk, 0),
+		locales: make(map[string]int),
+		config:  cfg,
+		index:   cfg.newIndex(),
 	}
 }
 
@@ -65,62 +162,75 @@ func NewVectorStore() *VectorStore {
 func (v *VectorStore) Add(chunk Chunk) {
 	v.mu.Lock()
 	defer v.mu.Unlock()
-	v.chunks = append(v.chunks, chunk)
+	v.add(chunk)
 }
 
 // AddBatch adds multiple chunks
 func (v *VectorStore) AddBatch(chunks []Chunk) {
 	v.mu.Lock()
 	defer v.mu.Unlock()
-	v.chunks = append(v.chunks, chunks...)
+	for _, chunk := range chunks {
+		v.add(chunk)
+	}
+}
+
+// add indexes a chunk. With int8 quantization the index gets the int8 codes;
+// the chunk keeps its float32 vector only when results are rescored.
+func (v *VectorStore) add(chunk Chunk) {
+	vector := chunk.Embedding
+	if v.config.Quantization == QuantizationInt8 {
+		vector = vector.Quantize()
+		if !v.config.rescores() {
+			chunk.Embedding = vector
+		}
+	}
+	v.chunks = append(v.chunks, chunk)
+	v.locales[chunk.Locale]++
+	v.index.Add(vector)
+}
+
+// Search finds the chunks most similar to a query vector
+func (v *VectorStore) Search(query Vector, opts SearchOptions) []SemanticResult {
+	return rerank(v.candidates(query, opts), opts)
 }
 
-// Search finds the most similar chunks to an embedding
-func (v *VectorStore) Search(queryEmbedding []float64, locale string, topK int) []SemanticResult {
+// candidates returns the chunks closest to the query, enough of them for
+// reranking to fill TopK
+func (v *VectorStore) candidates(query Vector, opts SearchOptions) []scoredChunk {
 	v.mu.RLock()
 	defer v.mu.RUnlock()
 
-	type scored struct {
-		chunk Chunk
-		score float64
-	}
-
-	var results []scored
-	for _, chunk := range v.chunks {
-		if locale != "" && chunk.Locale != locale {
-			continue
+	// Filters run inside the index, before scoring, so they never shrink the pool
+	var accept func(pos int) bool
+	filterLocale := opts.Locale != "" && v.locales[opts.Locale] != len(v.chunks)
+	if filterLocale || !opts.Filter.empty() || opts.exclude != nil {
+		accept = func(pos int) bool {
+			chunk := &v.chunks[pos]
+			return (!filterLocale || chunk.Locale == opts.Locale) && opts.accepts(chunk)
 		}
-		score := cosineSimilarity(queryEmbedding, chunk.Embedding)
-		results = append(results, scored{chunk: chunk, score: score})
 	}
 
-	// Sort by score descending
-	for i := 0; i < len(results); i++ {
-		for j := i + 1; j < len(results); j++ {
-			if results[j].score > results[i].score {
-				results[i], results[j] = results[j], results[i]
-			}
-		}
+	k := opts.poolSize()
+	if !v.config.rescores() {
+		return v.scored(v.index.Search(query, k, accept))
 	}
 
-	// Take top K
-	if len(results) > topK {
-		results = results[:topK]
+	// Take Rescore*k candidates in integer arithmetic against the quantized
+	// query, then keep the best k by their float32 vectors
+	top := newTopK(k)
+	for _, n := range v.index.Search(query.Quantize(), k*v.config.Rescore, accept) {
+		top.offer(Neighbor{Pos: n.Pos, Score: v.chunks[n.Pos].Embedding.Dot(query)})
 	}
+	return v.scored(top.sorted())
+}
 
-	var semanticResults []SemanticResult
-	for _, r := range results {
-		semanticResults = append(semanticResults, SemanticResult{
-			ChapterID:   r.chunk.ChapterID,
-			ChapterName: r.chunk.ChapterName,
-			Section:     r.chunk.Section,
-			Content:     r.chunk.Content,
-			Score:       r.score,
-			Locale:      r.chunk.Locale,
-		})
+// scored pairs index hits with their chunks; callers hold v.mu
+func (v *VectorStore) scored(neighbors []Neighbor) []scoredChunk {
+	candidates := make([]scoredChunk, len(neighbors))
+	for i, n := range neighbors {
+		candidates[i] = scoredChunk{chunk: v.chunks[n.Pos], score: n.Score}
 	}
-
-	return semanticResults
+	return candidates
 }
 
 // Count returns the number of chunks
@@ -130,31 +240,13 @@ func (v *VectorStore) Count() int {
 	return len(v.chunks)
 }
 
-// Clear clears the store
-func (v *VectorStore) Clear() {
-	v.mu.Lock()
-	defer v.mu.Unlock()
-	v.chunks = make([]Chunk, 0)
-}
-
-// cosineSimilarity calculates cosine similarity between two vectors
-func cosineSimilarity(a, b []float64) float64 {
-	if len(a) != len(b) {
-		return 0
-	}
-
-	var dotProduct, normA, normB float64
-	for i := range a {
-		dotProduct += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
-	}
-
-	if normA == 0 || normB == 0 {
-		return 0
-	}
-
-	return dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
+// snapshot returns a copy of all stored chunks
+func (v *VectorStore) snapshot() []Chunk {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	chunks := make([]Chunk, len(v.chunks))
+	copy(chunks, v.chunks)
+	return chunks
 }
 
 // ============================================
@@ -162,14 +254,65 @@ func cosineSimilarity(a, b []float64) float64 {
 // ============================================
 
 type OpenAIClient struct {
+	provider   Provider
+	baseURL    string
 	apiKey     string
 	model      string
+	dimensions int
+	headers    map[string]string
 	httpClient *http.Client
+
+	retry       retryPolicy
+	limiter     *rateLimiter
+	batchTokens int // estimated tokens per request, 0 for no limit
+}
+
+// OpenAILimits controls how fast the OpenAI client sends requests
+type OpenAILimits struct {
+	RequestsPerMinute int // 0 for no limit
+	TokensPerMinute   int // 0 for no limit
+	BatchTokens       int // estimated tokens per request, larger batches are split
+	MaxRetries        int // retries for 429, 5xx and connection errors
+}
+
+// DefaultOpenAILimits stays under the lowest paid OpenAI tier for embeddings
+// and well below the 300k tokens allowed per request. Self-hosted
+// OpenAI-compatible servers have no rate limits by default.
+func DefaultOpenAILimits(provider Provider) OpenAILimits {
+	limits := OpenAILimits{BatchTokens: 100000, MaxRetries: 5}
+	if provider == ProviderOpenAI {
+		limits.RequestsPerMinute = 3000
+		limits.TokensPerMinute = 1000000
+	}
+	return limits
+}
+
+// OpenAILimitsFromEnv reads OPENAI_RPM, OPENAI_TPM, OPENAI_BATCH_TOKENS and
+// OPENAI_MAX_RETRIES over the defaults for provider
+func OpenAILimitsFromEnv(provider Provider) OpenAILimits {
+	limits := DefaultOpenAILimits(provider)
+	limits.RequestsPerMinute = envInt("OPENAI_RPM", limits.RequestsPerMinute)
+	limits.TokensPerMinute = envInt("OPENAI_TPM", limits.TokensPerMinute)
+	limits.BatchTokens = envInt("OPENAI_BATCH_TOKENS", limits.BatchTokens)
+	limits.MaxRetries = max(0, envInt("OPENAI_MAX_RETRIES", limits.MaxRetries))
+	return limits
+}
+
+// SetLimits replaces the rate limits, batch size and retries of the client
+func (c *OpenAIClient) SetLimits(limits OpenAILimits) {
+	c.limiter = newRateLimiter(limits.RequestsPerMinute, limits.TokensPerMinute)
+	c.batchTokens = limits.BatchTokens
+	c.retry = retryPolicy{
+		maxRetries: limits.MaxRetries,
+		baseDelay:  time.Second,
+		maxDelay:   30 * time.Second,
+	}
 }
 
 type openAIRequest struct {
-	Input []string `json:"input"`
-	Model string   `json:"model"`
+	Input      []string `json:"input"`
+	Model      string   `json:"model"`
+	Dimensions int      `json:"dimensions,omitempty"`
 }
 
 type openAIResponse struct {
@@ -182,18 +325,85 @@ type openAIResponse struct {
 	} `json:"error,omitempty"`
 }
 
+// OpenAICompatibleConfig configures a client for any server implementing the
+// OpenAI embeddings API (LM Studio, vLLM, llama.cpp server, LocalAI, gateways)
+type OpenAICompatibleConfig struct {
+	BaseURL    string            // API root, e.g. http://localhost:1234/v1
+	Model      string            // model name as the server knows it
+	Dimensions int               // requested output size; 0 lets the server decide
+	APIKey     string            // sent as a Bearer token when set
+	Headers    map[string]string // extra headers sent with every request
+}
+
 // NewOpenAIClient creates an OpenAI client
 func NewOpenAIClient(apiKey string) *OpenAIClient {
 	if apiKey == "" {
 		apiKey = os.Getenv("OPENAI_API_KEY")
 	}
-	return &OpenAIClient{
-		apiKey: apiKey,
-		model:  "text-embedding-3-small",
+	client := &OpenAIClient{
+		provider: ProviderOpenAI,
+		baseURL:  "https://api.openai.com/v1",
+		apiKey:   apiKey,
+		model:    "text-embedding-3-small",
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
+	client.SetLimits(OpenAILimitsFromEnv(ProviderOpenAI))
+	return client
+}
+
+// NewOpenAICompatibleClient creates a client for an OpenAI-compatible embeddings server
+func NewOpenAICompatibleClient(cfg OpenAICompatibleConfig) (*OpenAIClient, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("base URL not set")
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("model not set")
+	}
+	client := &OpenAIClient{
+		provider:   ProviderOpenAICompatible,
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		apiKey:     cfg.APIKey,
+		model:      cfg.Model,
+		dimensions: cfg.Dimensions,
+		headers:    cfg.Headers,
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+	client.SetLimits(OpenAILimitsFromEnv(ProviderOpenAICompatible))
+	return client, nil
+}
+
+// OpenAICompatibleConfigFromEnv reads EMBEDDINGS_BASE_URL, EMBEDDINGS_MODEL,
+// EMBEDDINGS_DIMENSIONS, EMBEDDINGS_API_KEY and EMBEDDINGS_HEADERS. Headers
+// are given as comma-separated Name=Value pairs.
+func OpenAICompatibleConfigFromEnv() OpenAICompatibleConfig {
+	cfg := OpenAICompatibleConfig{
+		BaseURL:    os.Getenv("EMBEDDINGS_BASE_URL"),
+		Model:      os.Getenv("EMBEDDINGS_MODEL"),
+		Dimensions: envInt("EMBEDDINGS_DIMENSIONS", 0),
+		APIKey:     os.Getenv("EMBEDDINGS_API_KEY"),
+		Headers:    make(map[string]string),
+	}
+	for _, pair := range strings.Split(os.Getenv("EMBEDDINGS_HEADERS"), ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(name) != "" {
+			cfg.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return cfg
+}
+
+// Model returns the embedding model name
+func (c *OpenAIClient) Model() string {
+	return c.model
+}
+
+// BaseURL returns the API root requests are sent to
+func (c *OpenAIClient) BaseURL() string {
+	return c.baseURL
 }
 
 func (c *OpenAIClient) Embed(ctx context.Context, text string) ([]float64, error) {
@@ -207,14 +417,44 @@ func (c *OpenAIClient) Embed(ctx context.Context, text string) ([]float64, error
 	return embeddings[0], nil
 }
 
+// EmbedBatch embeds texts in requests of at most batchTokens estimated
+// tokens, waiting on the rate limiter before each one and retrying rate
+// limited and transient failures
 func (c *OpenAIClient) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
-	if c.apiKey == "" {
+	if c.apiKey == "" && c.provider == ProviderOpenAI {
 		return nil, fmt.Errorf("OpenAI API key not set")
 	}
 
+	embeddings := make([][]float64, 0, len(texts))
+	for _, batch := range splitByTokens(texts, c.batchTokens) {
+		tokens := 0
+		for _, text := range batch {
+			tokens += book.EstimateTokens(text)
+		}
+
+		var batchEmbeddings [][]float64
+		err := c.retry.do(ctx, func() error {
+			if err := c.limiter.wait(ctx, tokens); err != nil {
+				return err
+			}
+			var err error
+			batchEmbeddings, err = c.embedOnce(ctx, batch)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, batchEmbeddings...)
+	}
+	return embeddings, nil
+}
+
+// embedOnce sends a single embeddings request
+func (c *OpenAIClient) embedOnce(ctx context.Context, texts []string) ([][]float64, error) {
 	reqBody := openAIRequest{
-		Input: texts,
-		Model: c.model,
+		Input:      texts,
+		Model:      c.model,
+		Dimensions: c.dimensions,
 	}
 
 	body, err := json.Marshal(reqBody)
@@ -222,13 +462,18 @@ func (c *OpenAIClient) EmbedBatch(ctx context.Context, texts []string) ([][]floa
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/embeddings", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/embeddings", bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
 	req.Header.Set("Content-Type", "application/json")
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -242,8 +487,22 @@ func (c *OpenAIClient) EmbedBatch(ctx context.Context, texts []string) ([][]floa
 	}
 
 	var openAIResp openAIResponse
-	if err := json.Unmarshal(respBody, &openAIResp); err != nil {
-		return nil, err
+	jsonErr := json.Unmarshal(respBody, &openAIResp)
+
+	if resp.StatusCode >= 400 {
+		message := strings.TrimSpace(string(respBody))
+		if jsonErr == nil && openAIResp.Error != nil {
+			message = openAIResp.Error.Message
+		}
+		return nil, &APIError{
+			Provider:   c.provider,
+			StatusCode: resp.StatusCode,
+			Message:    message,
+			RetryAfter: parseRetryAfter(resp.Header),
+		}
+	}
+	if jsonErr != nil {
+		return nil, jsonErr
 	}
 
 	if openAIResp.Error != nil {
@@ -253,6 +512,9 @@ func (c *OpenAIClient) EmbedBatch(ctx context.Context, texts []string) ([][]floa
 	// Sort by index
 	embeddings := make([][]float64, len(texts))
 	for _, d := range openAIResp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range for %d inputs", d.Index, len(texts))
+		}
 		embeddings[d.Index] = d.Embedding
 	}
 
@@ -264,11 +526,19 @@ func (c *OpenAIClient) EmbedBatch(ctx context.Context, texts []string) ([][]floa
 // ============================================
 
 type OllamaClient struct {
-	baseURL    string
-	model      string
-	httpClient *http.Client
+	baseURL     string
+	model       string
+	truncate    bool
+	keepAlive   string
+	concurrency int
+	retry       retryPolicy
+	httpClient  *http.Client
+
+	// legacyOnly is set once the server turns out not to support /api/embed
+	legacyOnly atomic.Bool
 }
 
+// ollamaRequest is the legacy /api/embeddings request (one prompt per call)
 type ollamaRequest struct {
 	Model  string `json:"model"`
 	Prompt string `json:"prompt"`
@@ -279,7 +549,25 @@ type ollamaResponse struct {
 	Error     string    `json:"error,omitempty"`
 }
 
-// NewOllamaClient creates an Ollama client
+// ollamaEmbedRequest is the /api/embed request (Ollama 0.3.4+), which accepts a batch of inputs
+type ollamaEmbedRequest struct {
+	Model     string   `json:"model"`
+	Input     []string `json:"input"`
+	Truncate  *bool    `json:"truncate,omitempty"`
+	KeepAlive string   `json:"keep_alive,omitempty"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings [][]float64 `json:"embeddings"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// errEmbedEndpointMissing means the server predates /api/embed
+var errEmbedEndpointMissing = errors.New("Ollama /api/embed endpoint not available")
+
+// NewOllamaClient creates an Ollama client. Concurrency and retries are read
+// from OLLAMA_CONCURRENCY and OLLAMA_MAX_RETRIES, batch options from
+// OLLAMA_TRUNCATE and OLLAMA_KEEP_ALIVE.
 func NewOllamaClient(baseURL string, model string) *OllamaClient {
 	if baseURL == "" {
 		baseURL = os.Getenv("OLLAMA_BASE_URL")
@@ -294,15 +582,129 @@ func NewOllamaClient(baseURL string, model string) *OllamaClient {
 		}
 	}
 	return &OllamaClient{
-		baseURL: baseURL,
-		model:   model,
+		baseURL:     baseURL,
+		model:       model,
+		truncate:    os.Getenv("OLLAMA_TRUNCATE") != "false",
+		keepAlive:   os.Getenv("OLLAMA_KEEP_ALIVE"),
+		concurrency: max(1, envInt("OLLAMA_CONCURRENCY", 4)),
+		retry: retryPolicy{
+			maxRetries: max(0, envInt("OLLAMA_MAX_RETRIES", 3)),
+			baseDelay:  500 * time.Millisecond,
+			maxDelay:   10 * time.Second,
+		},
 		httpClient: &http.Client{
 			Timeout: 60 * time.Second,
 		},
 	}
 }
 
+// Model returns the embedding model name
+func (c *OllamaClient) Model() string {
+	return c.model
+}
+
+// BaseURL returns the Ollama server address
+func (c *OllamaClient) BaseURL() string {
+	return c.baseURL
+}
+
+// Embed embeds a single text, retrying connection errors and 5xx responses
 func (c *OllamaClient) Embed(ctx context.Context, text string) ([]float64, error) {
+	if !c.legacyOnly.Load() {
+		embeddings, err := c.embedNative(ctx, []string{text})
+		if !errors.Is(err, errEmbedEndpointMissing) {
+			if err != nil {
+				return nil, err
+			}
+			return embeddings[0], nil
+		}
+	}
+	return c.embedLegacy(ctx, text)
+}
+
+// embedNative embeds a batch of texts with a single /api/embed request
+func (c *OllamaClient) embedNative(ctx context.Context, texts []string) ([][]float64, error) {
+	var embeddings [][]float64
+	err := c.retry.do(ctx, func() error {
+		var err error
+		embeddings, err = c.embedNativeOnce(ctx, texts)
+		return err
+	})
+	if errors.Is(err, errEmbedEndpointMissing) {
+		log.Printf("Ollama at %s does not support /api/embed, falling back to /api/embeddings", c.baseURL)
+		c.legacyOnly.Store(true)
+	}
+	return embeddings, err
+}
+
+func (c *OllamaClient) embedNativeOnce(ctx context.Context, texts []string) ([][]float64, error) {
+	reqBody := ollamaEmbedRequest{
+		Model:     c.model,
+		Input:     texts,
+		Truncate:  &c.truncate,
+		KeepAlive: c.keepAlive,
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/embed", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Ollama connection error: %w (is Ollama running?)", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var embedResp ollamaEmbedResponse
+	if err := json.Unmarshal(respBody, &embedResp); err != nil {
+		// Old servers answer unknown routes with a plain-text 404
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, errEmbedEndpointMissing
+		}
+		if resp.StatusCode >= 400 {
+			return nil, &APIError{Provider: ProviderOllama, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(respBody)), RetryAfter: parseRetryAfter(resp.Header)}
+		}
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, &APIError{Provider: ProviderOllama, StatusCode: resp.StatusCode, Message: embedResp.Error, RetryAfter: parseRetryAfter(resp.Header)}
+	}
+	if embedResp.Error != "" {
+		return nil, fmt.Errorf("Ollama error: %s", embedResp.Error)
+	}
+	if len(embedResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("Ollama returned %d embeddings for %d inputs", len(embedResp.Embeddings), len(texts))
+	}
+
+	return embedResp.Embeddings, nil
+}
+
+// embedLegacy embeds a single text with the legacy /api/embeddings endpoint
+func (c *OllamaClient) embedLegacy(ctx context.Context, text string) ([]float64, error) {
+	var embedding []float64
+	err := c.retry.do(ctx, func() error {
+		var err error
+		embedding, err = c.embedLegacyOnce(ctx, text)
+		return err
+	})
+	return embedding, err
+}
+
+func (c *OllamaClient) embedLegacyOnce(ctx context.Context, text string) ([]float64, error) {
 	reqBody := ollamaRequest{
 		Model:  c.model,
 		Prompt: text,
@@ -333,9 +735,15 @@ func (c *OllamaClient) Embed(ctx context.Context, text string) ([]float64, error
 
 	var ollamaResp ollamaResponse
 	if err := json.Unmarshal(respBody, &ollamaResp); err != nil {
+		if resp.StatusCode >= 400 {
+			return nil, &APIError{Provider: ProviderOllama, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(respBody)), RetryAfter: parseRetryAfter(resp.Header)}
+		}
 		return nil, err
 	}
 
+	if resp.StatusCode >= 400 {
+		return nil, &APIError{Provider: ProviderOllama, StatusCode: resp.StatusCode, Message: ollamaResp.Error, RetryAfter: parseRetryAfter(resp.Header)}
+	}
 	if ollamaResp.Error != "" {
 		return nil, fmt.Errorf("Ollama error: %s", ollamaResp.Error)
 	}
@@ -343,16 +751,78 @@ func (c *OllamaClient) Embed(ctx context.Context, text string) ([]float64, error
 	return ollamaResp.Embedding, nil
 }
 
+// EmbedBatch embeds texts with one /api/embed request. If the server only
+// supports the legacy endpoint, texts are embedded one by one with a bounded
+// pool of concurrent requests. If the batch request fails after its retries,
+// each text gets one more attempt on its own so that failures can be
+// attributed: the successful embeddings are returned along with a
+// *BatchError listing the texts that failed.
 func (c *OllamaClient) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
-	// Ollama doesn't support native batch, process sequentially
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	if !c.legacyOnly.Load() {
+		embeddings, err := c.embedNative(ctx, texts)
+		if err == nil {
+			return embeddings, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !errors.Is(err, errEmbedEndpointMissing) {
+			// The batch was already retried: one attempt per text is enough
+			// to tell which texts fail
+			log.Printf("Ollama batch request failed (%v), trying texts individually", err)
+			return c.embedEach(withoutRetries(ctx), texts)
+		}
+	}
+
+	return c.embedEach(ctx, texts)
+}
+
+// embedEach embeds texts individually with a bounded worker pool
+func (c *OllamaClient) embedEach(ctx context.Context, texts []string) ([][]float64, error) {
 	embeddings := make([][]float64, len(texts))
-	for i, text := range texts {
-		emb, err := c.Embed(ctx, text)
+	errs := make([]error, len(texts))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(c.concurrency, len(texts)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				embeddings[i], errs[i] = c.Embed(ctx, texts[i])
+			}
+		}()
+	}
+
+feed:
+	for i := range texts {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var batchErr BatchError
+	for i, err := range errs {
 		if err != nil {
-			return nil, fmt.Errorf("error embedding text %d: %w", i, err)
+			batchErr.Failures = append(batchErr.Failures, EmbedFailure{Index: i, Err: err})
 		}
-		embeddings[i] = emb
 	}
+	if len(batchErr.Failures) > 0 {
+		return embeddings, &batchErr
+	}
+
 	return embeddings, nil
 }
 
@@ -360,17 +830,30 @@ func (c *OllamaClient) EmbedBatch(ctx context.Context, texts []string) ([][]floa
 // SEMANTIC ENGINE
 // ============================================
 
-// SemanticEngine combines the embeddings client with the vector store
+// SemanticEngine combines the embeddings client with one vector store per
+// locale. Published stores are never modified: builds construct fresh stores
+// in the background and swap them in, so searches always see a complete index.
 type SemanticEngine struct {
-	client     EmbeddingClient
-	store      *VectorStore
-	isIndexed  bool
-	indexMutex sync.Mutex
+	client      EmbeddingClient
+	provider    Provider
+	model       string
+	baseURL     string // empty for clients without a server
+	indexConfig IndexConfig
+	queryCache  *queryCache // nil when disabled
+	stats       engineStats
+
+	mu      sync.RWMutex // guards indexes, info and builtAt
+	indexes map[string]*VectorStore
+	info    IndexInfo
+	builtAt time.Time
+
+	indexMutex sync.Mutex // serializes index builds
 }
 
 // NewSemanticEngine creates a new semantic engine
 func NewSemanticEngine(provider Provider) (*SemanticEngine, error) {
 	var client EmbeddingClient
+	var model string
 
 	switch provider {
 	case ProviderOpenAI:
@@ -378,40 +861,250 @@ func NewSemanticEngine(provider Provider) (*SemanticEngine, error) {
 		if apiKey == "" {
 			return nil, fmt.Errorf("OPENAI_API_KEY not set")
 		}
-		client = NewOpenAIClient(apiKey)
+		openAI := NewOpenAIClient(apiKey)
+		client, model = openAI, openAI.Model()
 	case ProviderOllama:
-		client = NewOllamaClient("", "")
+		ollama := NewOllamaClient("", "")
+		client, model = ollama, ollama.Model()
+	case ProviderOpenAICompatible:
+		compatible, err := NewOpenAICompatibleClient(OpenAICompatibleConfigFromEnv())
+		if err != nil {
+			return nil, fmt.Errorf("EMBEDDINGS_BASE_URL/EMBEDDINGS_MODEL: %w", err)
+		}
+		client, model = compatible, compatible.Model()
+	case ProviderLocal:
+		local := NewLocalClient(0)
+		client, model = local, local.Model()
 	default:
 		return nil, fmt.Errorf("unknown provider: %s", provider)
 	}
 
-	return &SemanticEngine{
-		client:    client,
-		store:     NewVectorStore(),
-		isIndexed: false,
-	}, nil
+	return newSemanticEngine(client, provider, model), nil
+}
+
+func newSemanticEngine(client EmbeddingClient, provider Provider, model string) *SemanticEngine {
+	e := &SemanticEngine{
+		client:      client,
+		provider:    provider,
+		model:       model,
+		indexConfig: IndexConfigFromEnv(),
+		queryCache:  newQueryCache(QueryCacheConfigFromEnv()),
+		indexes:     make(map[string]*VectorStore),
+	}
+	if server, ok := client.(interface{ BaseURL() string }); ok {
+		e.baseURL = server.BaseURL()
+	}
+	return e
 }
 
 // IsAvailable checks if the engine is available
 func (e *SemanticEngine) IsAvailable() bool {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(withoutRetries(context.Background()), 5*time.Second)
 	defer cancel()
 
 	_, err := e.client.Embed(ctx, "test")
 	return err == nil
 }
 
-// IndexChunks indexes a list of chunks
-func (e *SemanticEngine) IndexChunks(ctx context.Context, chunks []Chunk) error {
+// ProbeDimensions embeds a short text to find the vector size the provider
+// currently returns
+func (e *SemanticEngine) ProbeDimensions(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(withoutRetries(ctx), 10*time.Second)
+	defer cancel()
+
+	embedding, err := e.client.Embed(ctx, "test")
+	if err != nil {
+		return 0, err
+	}
+	return len(embedding), nil
+}
+
+// ProgressFunc is called after each embedding batch with the number of chunks
+// embedded so far and the total number of chunks to embed
+type ProgressFunc func(done, total int)
+
+// IndexChunks embeds all chunks and replaces the index of every locale they
+// belong to. Chunks that fail to embed are left out and listed in the stats.
+func (e *SemanticEngine) IndexChunks(ctx context.Context, chunks []Chunk, progress ProgressFunc) (stats *IndexStats, err error) {
+	e.indexMutex.Lock()
+	defer e.indexMutex.Unlock()
+	defer func(start time.Time) { e.stats.build(start, err) }(time.Now())
+
+	failures, err := e.embedChunks(ctx, chunks, progress)
+	if err != nil {
+		return nil, err
+	}
+	dims, err := e.checkDimensions(chunks, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var embedded []Chunk
+	for _, chunk := range chunks {
+		if chunk.Embedding.Len() > 0 {
+			embedded = append(embedded, chunk)
+		}
+	}
+	if len(embedded) == 0 && len(chunks) > 0 {
+		return nil, fmt.Errorf("all %d chunks failed to embed: %s", len(chunks), failures[0].Error)
+	}
+
+	e.swap(e.groupByLocale(embedded), IndexInfo{Provider: e.provider, Model: e.model, Dimensions: dims})
+
+	return &IndexStats{
+		Added:    len(embedded),
+		Failed:   len(failures),
+		Failures: failures,
+	}, nil
+}
+
+// IndexIncremental rebuilds the index for the given locales, embedding only
+// chunks whose content hash is not already indexed. Indexed chunks of those
+// locales that are no longer present in chunks are dropped. A section is
+// replaced as a whole: when any of its new parts fails to embed, its previous
+// parts are kept and none of the new ones. An index built with a different
+// provider or model cannot be updated and needs a full build.
+func (e *SemanticEngine) IndexIncremental(ctx context.Context, locales []string, chunks []Chunk, progress ProgressFunc) (_ *IndexStats, err error) {
 	e.indexMutex.Lock()
 	defer e.indexMutex.Unlock()
+	defer func(start time.Time) { e.stats.build(start, err) }(time.Now())
+
+	if err := e.CheckIndex(); err != nil {
+		return nil, err
+	}
+	info := e.IndexInfo()
+
+	// Group the indexed and the new chunks by section, in reading order
+	sources := make(map[string]*sourceUpdate)
+	var order []string
+	source := func(chunk *Chunk) *sourceUpdate {
+		key := chunk.sourceKey()
+		if sources[key] == nil {
+			sources[key] = &sourceUpdate{}
+			order = append(order, key)
+		}
+		return sources[key]
+	}
+
+	existing := make(map[string]Chunk)
+	for _, chunk := range chunks {
+		source(&chunk) // new sections first, so the index follows the book
+	}
+	for _, locale := range locales {
+		for _, chunk := range e.store(locale).snapshot() {
+			existing[chunk.ID] = chunk
+			s := source(&chunk)
+			s.old = append(s.old, chunk)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var pending []Chunk
+	for _, chunk := range chunks {
+		if seen[chunk.ID] {
+			continue // identical chunk listed twice
+		}
+		seen[chunk.ID] = true
+
+		s := source(&chunk)
+		if indexed, ok := existing[chunk.ID]; ok {
+			// Same content: reuse the embedding but take the fresh metadata
+			chunk.Embedding = indexed.Embedding
+			s.unchanged++
+		} else {
+			pending = append(pending, chunk)
+		}
+		s.next = append(s.next, chunk)
+	}
+
+	failures, err := e.embedChunks(ctx, pending, progress)
+	if err != nil {
+		return nil, err
+	}
+	dims, err := e.checkDimensions(pending, info.Dimensions)
+	if err != nil {
+		return nil, err
+	}
+
+	embedded := make(map[string]Vector, len(pending))
+	for _, chunk := range pending {
+		if chunk.Embedding.Len() > 0 {
+			embedded[chunk.ID] = chunk.Embedding
+		}
+	}
+
+	stats := &IndexStats{Failed: len(failures), Failures: failures}
+	var next []Chunk
+	for _, key := range order {
+		next = append(next, sources[key].apply(embedded, stats)...)
+	}
+
+	// Locales in scope without chunks end up empty rather than keeping stale data
+	stores := e.groupByLocale(next)
+	for _, locale := range locales {
+		if _, ok := stores[locale]; !ok {
+			stores[locale] = NewVectorStore()
+		}
+	}
+	e.swap(stores, IndexInfo{Provider: e.provider, Model: e.model, Dimensions: dims})
+
+	return stats, nil
+}
+
+// sourceUpdate is the indexed and the new version of one section
+type sourceUpdate struct {
+	old       []Chunk
+	next      []Chunk // embedding set for unchanged chunks only
+	unchanged int
+}
+
+// apply returns the chunks the section keeps and counts the changes. Changed
+// parts pair up as updates; extra new parts are added and extra old parts
+// removed. A section whose update failed keeps its old parts unchanged, and a
+// new section keeps the parts that embedded.
+func (s *sourceUpdate) apply(embedded map[string]Vector, stats *IndexStats) []Chunk {
+	var kept []Chunk
+	changed := 0
+	for _, chunk := range s.next {
+		if chunk.Embedding.Len() == 0 {
+			vector, ok := embedded[chunk.ID]
+			if !ok {
+				continue
+			}
+			chunk.Embedding = vector
+			changed++
+		}
+		kept = append(kept, chunk)
+	}
+
+	failed := len(kept) < len(s.next)
+	if failed && len(s.old) > 0 {
+		stats.Unchanged += s.unchanged
+		return s.old
+	}
+
+	removed := len(s.old) - s.unchanged
+	updated := min(changed, removed)
+	stats.Unchanged += s.unchanged
+	stats.Updated += updated
+	stats.Added += changed - updated
+	stats.Removed += removed - updated
+	return kept
+}
 
+// embedChunks fills in the embedding of each chunk, reporting progress per
+// batch. Chunks that could not be embedded keep a nil embedding and are
+// returned as failures; only errors that abort the whole build are returned
+// as err.
+func (e *SemanticEngine) embedChunks(ctx context.Context, chunks []Chunk, progress ProgressFunc) ([]ChunkFailure, error) {
 	// Extract texts
 	texts := make([]string, len(chunks))
 	for i, chunk := range chunks {
-		texts[i] = chunk.Content
+		texts[i] = chunk.EmbedText()
 	}
 
+	var failures []ChunkFailure
+
 	// Generate embeddings in batches of 100
 	batchSize := 100
 	for i := 0; i < len(texts); i += batchSize {
@@ -421,41 +1114,294 @@ func (e *SemanticEngine) IndexChunks(ctx context.Context, chunks []Chunk) error
 		}
 
 		embeddings, err := e.client.EmbedBatch(ctx, texts[i:end])
-		if err != nil {
-			return fmt.Errorf("error generating embeddings: %w", err)
+		failed := make(map[int]bool)
+		var batchErr *BatchError
+		if errors.As(err, &batchErr) {
+			for _, f := range batchErr.Failures {
+				failed[f.Index] = true
+				failures = append(failures, chunkFailure(chunks[i+f.Index], f.Err.Error()))
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("error generating embeddings: %w", err)
+		}
+
+		// A provider may also leave texts out, or return zero vectors, without
+		// reporting an error
+		for j := range end - i {
+			var emb []float64
+			if j < len(embeddings) {
+				emb = embeddings[j]
+			}
+			vector := NewVector(emb)
+			if vector.Len() == 0 && !failed[j] {
+				reason := "no embedding returned"
+				if len(emb) > 0 {
+					reason = "embedding is all zeros"
+				}
+				failures = append(failures, chunkFailure(chunks[i+j], reason))
+			}
+			chunks[i+j].Embedding = vector
+		}
+
+		if progress != nil {
+			progress(end, len(texts))
+		}
+	}
+
+	return failures, nil
+}
+
+// chunkFailure describes a chunk that could not be embedded
+func chunkFailure(chunk Chunk, reason string) ChunkFailure {
+	return ChunkFailure{
+		ChunkID:   chunk.ID,
+		ChapterID: chunk.ChapterID,
+		Section:   chunk.Section,
+		Locale:    chunk.Locale,
+		Error:     reason,
+	}
+}
+
+// checkDimensions makes sure every embedded chunk has dims dimensions, or
+// the size of the first one when dims is 0, and returns that size
+func (e *SemanticEngine) checkDimensions(chunks []Chunk, dims int) (int, error) {
+	for _, chunk := range chunks {
+		n := chunk.Embedding.Len()
+		if n == 0 {
+			continue
+		}
+		if dims == 0 {
+			dims = n
+		}
+		if n != dims {
+			return 0, &MismatchError{
+				Index:   IndexInfo{Provider: e.provider, Model: e.model, Dimensions: dims},
+				Current: IndexInfo{Provider: e.provider, Model: e.model, Dimensions: n},
+			}
 		}
+	}
+	return dims, nil
+}
 
-		for j, emb := range embeddings {
-			chunks[i+j].Embedding = emb
+// swap atomically publishes freshly built stores, replacing those locales.
+// When the embedding space changed, the other locales are dropped as well
+// since their vectors are no longer comparable.
+func (e *SemanticEngine) swap(stores map[string]*VectorStore, info IndexInfo) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	indexes := make(map[string]*VectorStore, len(e.indexes)+len(stores))
+	if info == e.info || info.Dimensions == 0 {
+		for locale, store := range e.indexes {
+			indexes[locale] = store
 		}
 	}
+	if info.Dimensions == 0 {
+		info = e.info // nothing was embedded, the space is unchanged
+	}
+	for locale, store := range stores {
+		if store.Count() == 0 {
+			delete(indexes, locale)
+			continue
+		}
+		indexes[locale] = store
+	}
+
+	e.indexes = indexes
+	e.info = info
+	e.builtAt = time.Now()
+}
 
-	e.store.AddBatch(chunks)
-	e.isIndexed = true
+// store returns the published store of a locale (empty if not indexed)
+func (e *SemanticEngine) store(locale string) *VectorStore {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 
-	return nil
+	if store, ok := e.indexes[locale]; ok {
+		return store
+	}
+	return NewVectorStore()
+}
+
+// stores returns the published stores of all locales
+func (e *SemanticEngine) stores() map[string]*VectorStore {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.indexes
+}
+
+// groupByLocale builds one store per locale from a list of chunks, skipping
+// chunks whose ID was already seen
+func (e *SemanticEngine) groupByLocale(chunks []Chunk) map[string]*VectorStore {
+	seen := make(map[string]bool)
+	byLocale := make(map[string][]Chunk)
+	for _, chunk := range chunks {
+		if seen[chunk.ID] {
+			continue
+		}
+		seen[chunk.ID] = true
+		byLocale[chunk.Locale] = append(byLocale[chunk.Locale], chunk)
+	}
+
+	stores := make(map[string]*VectorStore, len(byLocale))
+	for locale, localeChunks := range byLocale {
+		store := NewVectorStoreWithIndex(e.indexConfig)
+		store.AddBatch(localeChunks)
+		stores[locale] = store
+	}
+	return stores
 }
 
 // Search performs a semantic search
-func (e *SemanticEngine) Search(ctx context.Context, query string, locale string, topK int) ([]SemanticResult, error) {
-	if !e.isIndexed {
+func (e *SemanticEngine) Search(ctx context.Context, query string, opts SearchOptions) (_ []SemanticResult, err error) {
+	if !e.IsIndexed() {
 		return nil, fmt.Errorf("index not built, call IndexChunks first")
 	}
+	if err := e.CheckIndex(); err != nil {
+		return nil, err
+	}
+	defer func(start time.Time) { e.stats.query(start, err) }(time.Now())
 
-	queryEmbedding, err := e.client.Embed(ctx, query)
+	queryVector, err := e.embedQuery(ctx, query)
 	if err != nil {
 		return nil, err
 	}
+	if queryVector.Len() == 0 {
+		return nil, nil // nothing to match, e.g. a query made only of stopwords
+	}
+
+	if info := e.IndexInfo(); queryVector.Len() != info.Dimensions {
+		return nil, &MismatchError{
+			Index:   info,
+			Current: IndexInfo{Provider: e.provider, Model: e.model, Dimensions: queryVector.Len()},
+		}
+	}
+
+	if len(opts.Locales) == 0 && opts.Locale != "" {
+		return e.store(opts.Locale).Search(queryVector, opts), nil
+	}
+
+	// Rerank the candidates of several locales together
+	var candidates []scoredChunk
+	if len(opts.Locales) > 0 {
+		for _, locale := range opts.Locales {
+			localeOpts := opts
+			localeOpts.Locale = locale
+			candidates = append(candidates, e.store(locale).candidates(queryVector, localeOpts)...)
+		}
+	} else {
+		for _, store := range e.stores() {
+			candidates = append(candidates, store.candidates(queryVector, opts)...)
+		}
+	}
+	return rerank(candidates, opts), nil
+}
+
+// embedQuery returns the vector of a query, from the cache when it was
+// embedded recently
+func (e *SemanticEngine) embedQuery(ctx context.Context, query string) (Vector, error) {
+	if e.queryCache == nil {
+		embedding, err := e.client.Embed(ctx, query)
+		return NewVector(embedding), err
+	}
+
+	key := queryCacheKey(e.provider, e.baseURL, e.model, query)
+	if vector, ok := e.queryCache.get(key); ok {
+		return vector, nil
+	}
+
+	embedding, err := e.client.Embed(ctx, query)
+	if err != nil {
+		return Vector{}, err
+	}
+	vector := NewVector(embedding)
+	e.queryCache.put(key, vector)
+	return vector, nil
+}
+
+// QueryCacheStats returns the hit rate and size of the query embedding cache
+func (e *SemanticEngine) QueryCacheStats() QueryCacheStats {
+	return e.queryCache.stats()
+}
 
-	return e.store.Search(queryEmbedding, locale, topK), nil
+// Close writes the query cache entries still waiting to be saved. Call it
+// before the process exits.
+func (e *SemanticEngine) Close() error {
+	return e.queryCache.flush()
 }
 
 // IsIndexed returns whether the index is built
 func (e *SemanticEngine) IsIndexed() bool {
-	return e.isIndexed
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.indexes) > 0
+}
+
+// IsLocaleIndexed returns whether a locale has indexed chunks
+func (e *SemanticEngine) IsLocaleIndexed(locale string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	_, ok := e.indexes[locale]
+	return ok
+}
+
+// IndexInfo returns the embedding space of the current index (zero if not indexed)
+func (e *SemanticEngine) IndexInfo() IndexInfo {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.info
+}
+
+// CheckIndex returns a *MismatchError when the current index was built with
+// another provider or model than the engine uses
+func (e *SemanticEngine) CheckIndex() error {
+	info := e.IndexInfo()
+	if !e.IsIndexed() || (info.Provider == e.provider && info.Model == e.model) {
+		return nil
+	}
+	return &MismatchError{Index: info, Current: IndexInfo{Provider: e.provider, Model: e.model}}
+}
+
+// Provider returns the embeddings provider used by the engine
+func (e *SemanticEngine) Provider() Provider {
+	return e.provider
+}
+
+// Model returns the embedding model used by the engine
+func (e *SemanticEngine) Model() string {
+	return e.model
 }
 
 // ChunkCount returns the number of indexed chunks
 func (e *SemanticEngine) ChunkCount() int {
-	return e.store.Count()
+	count := 0
+	for _, store := range e.stores() {
+		count += store.Count()
+	}
+	return count
+}
+
+// allChunks returns every indexed chunk, ordered by locale
+func (e *SemanticEngine) allChunks() []Chunk {
+	stores := e.stores()
+
+	locales := make([]string, 0, len(stores))
+	for locale := range stores {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+
+	var chunks []Chunk
+	for _, locale := range locales {
+		chunks = append(chunks, stores[locale].snapshot()...)
+	}
+	return chunks
+}
+
+// envInt reads an integer environment variable, falling back to def
+func envInt(name string, def int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		return value
+	}
+	return def
 }
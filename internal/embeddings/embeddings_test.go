@@ -0,0 +1,176 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// failingClient embeds like the local client but fails every text that
+// contains "FAIL"
+type failingClient struct {
+	*LocalClient
+}
+
+func (c failingClient) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	embeddings, err := c.LocalClient.EmbedBatch(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	var failures []EmbedFailure
+	for i, text := range texts {
+		if strings.Contains(text, "FAIL") {
+			embeddings[i] = nil
+			failures = append(failures, EmbedFailure{Index: i, Err: errors.New("rejected")})
+		}
+	}
+	if len(failures) > 0 {
+		return embeddings, &BatchError{Failures: failures}
+	}
+	return embeddings, nil
+}
+
+// section returns the chunks of a section, one per content, named like the
+// chunker names split sections
+func section(chapterID, sectionID string, contents ...string) []Chunk {
+	var chunks []Chunk
+	for i, content := range contents {
+		name := sectionID
+		if len(contents) > 1 {
+			name = fmt.Sprintf("%s (part %d)", sectionID, i+1)
+		}
+		chunk := Chunk{ChapterID: chapterID, Section: name, SectionID: sectionID, Content: content, Locale: "en"}
+		chunk.ID = ChunkID("en", chapterID, name, chunk.EmbedText())
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// indexedContents returns the sorted contents of every indexed chunk
+func indexedContents(e *SemanticEngine) []string {
+	var contents []string
+	for _, chunk := range e.allChunks() {
+		contents = append(contents, chunk.Content)
+	}
+	slices.Sort(contents)
+	return contents
+}
+
+func TestIndexIncremental(t *testing.T) {
+	engine := newSemanticEngine(failingClient{NewLocalClient(64)}, ProviderLocal, "failing")
+	ctx := context.Background()
+	locales := []string{"en"}
+
+	steps := []struct {
+		name   string
+		chunks [][]Chunk
+		want   IndexStats
+		index  []string
+	}{
+		{
+			name:   "add",
+			chunks: [][]Chunk{section("ch", "a", "a1", "a2", "a3"), section("ch", "b", "b1")},
+			want:   IndexStats{Added: 4},
+			index:  []string{"a1", "a2", "a3", "b1"},
+		},
+		{
+			name:   "update one part",
+			chunks: [][]Chunk{section("ch", "a", "a1", "a2 edited", "a3"), section("ch", "b", "b1")},
+			want:   IndexStats{Updated: 1, Unchanged: 3},
+			index:  []string{"a1", "a2 edited", "a3", "b1"},
+		},
+		{
+			name:   "shrink",
+			chunks: [][]Chunk{section("ch", "a", "a1", "a2 shortened"), section("ch", "b", "b1")},
+			want:   IndexStats{Updated: 1, Removed: 1, Unchanged: 2},
+			index:  []string{"a1", "a2 shortened", "b1"},
+		},
+		{
+			name:   "remove a section",
+			chunks: [][]Chunk{section("ch", "a", "a1", "a2 shortened")},
+			want:   IndexStats{Removed: 1, Unchanged: 2},
+			index:  []string{"a1", "a2 shortened"},
+		},
+		{
+			name:   "grow",
+			chunks: [][]Chunk{section("ch", "a", "a1", "a2 shortened", "a3 back")},
+			want:   IndexStats{Added: 1, Unchanged: 2},
+			index:  []string{"a1", "a2 shortened", "a3 back"},
+		},
+		{
+			// The update of a fails halfway: a keeps its old parts only, while
+			// the new section c keeps the part that embedded
+			name:   "partial failure",
+			chunks: [][]Chunk{section("ch", "a", "a1 new", "a2 FAIL"), section("ch", "c", "c1", "c2 FAIL")},
+			want:   IndexStats{Added: 1, Failed: 2},
+			index:  []string{"a1", "a2 shortened", "a3 back", "c1"},
+		},
+	}
+
+	for _, step := range steps {
+		stats, err := engine.IndexIncremental(ctx, locales, slices.Concat(step.chunks...), nil)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		got := [5]int{stats.Added, stats.Updated, stats.Removed, stats.Unchanged, stats.Failed}
+		want := [5]int{step.want.Added, step.want.Updated, step.want.Removed, step.want.Unchanged, step.want.Failed}
+		if got != want || len(stats.Failures) != stats.Failed {
+			t.Errorf("%s: stats %+v, want %+v", step.name, *stats, step.want)
+		}
+		if got := indexedContents(engine); !slices.Equal(got, step.index) {
+			t.Errorf("%s: index holds %v, want %v", step.name, got, step.index)
+		}
+	}
+}
+
+// silentClient embeds like the local client but, without reporting an error,
+// returns no vector for texts containing "SKIP", a zero vector for "ZERO" and
+// drops the last text of every batch
+type silentClient struct {
+	*LocalClient
+}
+
+func (c silentClient) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	embeddings, err := c.LocalClient.EmbedBatch(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	for i, text := range texts {
+		switch {
+		case strings.Contains(text, "SKIP"):
+			embeddings[i] = nil
+		case strings.Contains(text, "ZERO"):
+			embeddings[i] = make([]float64, len(embeddings[i]))
+		}
+	}
+	return embeddings[:len(embeddings)-1], nil
+}
+
+func TestIndexChunksCountsMissingEmbeddings(t *testing.T) {
+	engine := newSemanticEngine(silentClient{NewLocalClient(64)}, ProviderLocal, "silent")
+	chunks := section("ch", "a", "a1", "a2 SKIP", "a3 ZERO", "a4", "a5 dropped")
+
+	stats, err := engine.IndexChunks(context.Background(), chunks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Added != 2 || stats.Failed != 3 || len(stats.Failures) != 3 {
+		t.Fatalf("stats = %+v, want 2 added and 3 failed", *stats)
+	}
+	want := map[string]string{
+		chunks[1].ID: "no embedding returned",
+		chunks[2].ID: "embedding is all zeros",
+		chunks[4].ID: "no embedding returned",
+	}
+	for _, f := range stats.Failures {
+		if want[f.ChunkID] != f.Error {
+			t.Errorf("failure %+v, want error %q", f, want[f.ChunkID])
+		}
+	}
+	if got := indexedContents(engine); !slices.Equal(got, []string{"a1", "a4"}) {
+		t.Errorf("index holds %v", got)
+	}
+}
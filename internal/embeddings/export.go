@@ -0,0 +1,319 @@
+package embeddings
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// ============================================
+// PORTABLE EXPORT
+// ============================================
+
+// Export formats
+const (
+	ExportJSONL  = "jsonl"
+	ExportBinary = "binary"
+)
+
+// exportFormatName identifies export files in their header
+const exportFormatName = "gentleman-book-index"
+
+// exportVersion is bumped whenever the export layout changes
+const exportVersion = 1
+
+// binaryMagic starts every binary export
+var binaryMagic = []byte("GBMINDEX")
+
+// ExportHeader describes an exported index. It is the first line of a JSONL
+// export and follows the magic bytes of a binary export.
+type ExportHeader struct {
+	Format     string    `json:"format"` // always "gentleman-book-index"
+	Version    int       `json:"version"`
+	Provider   Provider  `json:"provider"`
+	Model      string    `json:"model"`
+	Dimensions int       `json:"dimensions"`
+	ChunkCount int       `json:"chunkCount"`
+	ExportedAt time.Time `json:"exportedAt"`
+}
+
+// exportChunk is a chunk with its vector as plain float32 values. Its
+// Embedding field shadows the one of Chunk in JSON.
+type exportChunk struct {
+	Chunk
+	Embedding []float32 `json:"embedding,omitempty"`
+}
+
+// Export writes every indexed chunk in the given format.
+//
+// JSONL: the first line is the ExportHeader, then one JSON object per chunk
+// with its metadata (the fields of Chunk) and "embedding" as an array of
+// numbers.
+//
+// Binary (little-endian): the 8 bytes "GBMINDEX", a uint32 header length and
+// the header JSON, then for each chunk a uint32 length and the chunk metadata
+// JSON (without embedding) followed by Dimensions float32 values.
+//
+// Vectors are unit length; quantized vectors are exported dequantized.
+func (e *SemanticEngine) Export(w io.Writer, format string) (*ExportHeader, error) {
+	return writeExport(w, format, e.IndexInfo(), e.allChunks())
+}
+
+// ExportIndexFile writes the index saved at path like Export does, reading
+// the file directly so no provider is needed
+func ExportIndexFile(path string, w io.Writer, format string) (*ExportHeader, error) {
+	meta, chunks, err := readIndexFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return writeExport(w, format, IndexInfo{Provider: meta.Provider, Model: meta.Model, Dimensions: meta.Dimensions}, chunks)
+}
+
+// writeExport writes chunks built in the embedding space info in format
+func writeExport(w io.Writer, format string, info IndexInfo, chunks []Chunk) (*ExportHeader, error) {
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("index not built, nothing to export")
+	}
+
+	header := &ExportHeader{
+		Format:     exportFormatName,
+		Version:    exportVersion,
+		Provider:   info.Provider,
+		Model:      info.Model,
+		Dimensions: dimensionsOf(chunks),
+		ChunkCount: len(chunks),
+		ExportedAt: time.Now().UTC(),
+	}
+
+	bw := bufio.NewWriter(w)
+	var err error
+	switch format {
+	case ExportJSONL:
+		err = writeJSONL(bw, header, chunks)
+	case ExportBinary:
+		err = writeBinary(bw, header, chunks)
+	default:
+		return nil, fmt.Errorf("unknown export format %q, use %s or %s", format, ExportJSONL, ExportBinary)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error writing export: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, fmt.Errorf("error writing export: %w", err)
+	}
+	return header, nil
+}
+
+func writeJSONL(w io.Writer, header *ExportHeader, chunks []Chunk) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if err := enc.Encode(exportChunk{Chunk: chunk, Embedding: chunk.Embedding.Float32()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeBinary(w io.Writer, header *ExportHeader, chunks []Chunk) error {
+	if _, err := w.Write(binaryMagic); err != nil {
+		return err
+	}
+	if err := writeBlock(w, header); err != nil {
+		return err
+	}
+
+	for _, chunk := range chunks {
+		if err := writeBlock(w, exportChunk{Chunk: chunk}); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, chunk.Embedding.Float32()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBlock writes v as JSON prefixed with its uint32 length
+func writeBlock(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadExport reads an export in either format, detected from its first bytes,
+// and checks that it is complete and every vector has the declared size
+func ReadExport(r io.Reader) (*ExportHeader, []Chunk, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(binaryMagic))
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+
+	var header *ExportHeader
+	var chunks []Chunk
+	if bytes.Equal(magic, binaryMagic) {
+		header, chunks, err = readBinary(br)
+	} else {
+		header, chunks, err = readJSONL(br)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(chunks) != header.ChunkCount {
+		return nil, nil, fmt.Errorf("export declares %d chunks but contains %d", header.ChunkCount, len(chunks))
+	}
+	for _, chunk := range chunks {
+		if chunk.Embedding.Len() != header.Dimensions {
+			return nil, nil, fmt.Errorf("chunk %s has %d dimensions, export declares %d", chunk.ID, chunk.Embedding.Len(), header.Dimensions)
+		}
+	}
+	return header, chunks, nil
+}
+
+func checkHeader(header *ExportHeader) error {
+	if header.Format != exportFormatName {
+		return fmt.Errorf("not an index export (format %q)", header.Format)
+	}
+	if header.Version < 1 || header.Version > exportVersion {
+		return fmt.Errorf("unsupported export version %d (expected %d)", header.Version, exportVersion)
+	}
+	if header.Dimensions <= 0 {
+		return fmt.Errorf("invalid dimensions %d", header.Dimensions)
+	}
+	return nil
+}
+
+func readJSONL(r *bufio.Reader) (*ExportHeader, []Chunk, error) {
+	dec := json.NewDecoder(r)
+
+	var header ExportHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, nil, fmt.Errorf("invalid export header: %w", err)
+	}
+	if err := checkHeader(&header); err != nil {
+		return nil, nil, err
+	}
+
+	var chunks []Chunk
+	for line := 2; ; line++ {
+		var ec exportChunk
+		if err := dec.Decode(&ec); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("invalid chunk on line %d: %w", line, err)
+		}
+		chunks = append(chunks, ec.chunk())
+	}
+	return &header, chunks, nil
+}
+
+func readBinary(r *bufio.Reader) (*ExportHeader, []Chunk, error) {
+	if _, err := r.Discard(len(binaryMagic)); err != nil {
+		return nil, nil, err
+	}
+
+	var header ExportHeader
+	if err := readBlock(r, &header); err != nil {
+		return nil, nil, fmt.Errorf("invalid export header: %w", err)
+	}
+	if err := checkHeader(&header); err != nil {
+		return nil, nil, err
+	}
+
+	chunks := make([]Chunk, 0, header.ChunkCount)
+	for {
+		if _, err := r.Peek(1); err == io.EOF {
+			break
+		}
+
+		var ec exportChunk
+		if err := readBlock(r, &ec); err != nil {
+			return nil, nil, fmt.Errorf("invalid chunk %d: %w", len(chunks)+1, err)
+		}
+		ec.Embedding = make([]float32, header.Dimensions)
+		if err := binary.Read(r, binary.LittleEndian, ec.Embedding); err != nil {
+			return nil, nil, fmt.Errorf("invalid vector of chunk %s: %w", ec.ID, err)
+		}
+		chunks = append(chunks, ec.chunk())
+	}
+	return &header, chunks, nil
+}
+
+// maxBlockSize bounds a JSON block so a corrupt length cannot exhaust memory
+const maxBlockSize = 16 << 20
+
+// readBlock reads a uint32 length-prefixed JSON block into v
+func readBlock(r io.Reader, v any) error {
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return err
+	}
+	if size > maxBlockSize {
+		return fmt.Errorf("block of %d bytes is too large", size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// chunk returns the chunk with its vector normalized
+func (ec exportChunk) chunk() Chunk {
+	chunk := ec.Chunk
+	values := make([]float64, len(ec.Embedding))
+	for i, x := range ec.Embedding {
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return chunk // left empty, fails the dimension check
+		}
+		values[i] = float64(x)
+	}
+	chunk.Embedding = NewVector(values)
+	return chunk
+}
+
+// Import replaces the current index with exported chunks. The export must
+// come from the provider and model the engine uses, otherwise a
+// *MismatchError is returned and the index is left untouched.
+func (e *SemanticEngine) Import(header *ExportHeader, chunks []Chunk) error {
+	if header.Provider != e.provider || header.Model != e.model {
+		return &MismatchError{
+			Index:   IndexInfo{Provider: header.Provider, Model: header.Model, Dimensions: header.Dimensions},
+			Current: IndexInfo{Provider: e.provider, Model: e.model},
+		}
+	}
+	if len(chunks) == 0 {
+		return fmt.Errorf("export has no chunks")
+	}
+
+	// Build the stores before taking the locks, graph indexes take a while
+	stores := e.groupByLocale(chunks)
+
+	e.indexMutex.Lock()
+	defer e.indexMutex.Unlock()
+
+	e.mu.Lock()
+	e.indexes = stores
+	e.info = IndexInfo{Provider: header.Provider, Model: header.Model, Dimensions: header.Dimensions}
+	e.builtAt = header.ExportedAt
+	e.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// exportTestEngine returns a local engine indexing a few chunks of two locales
+// with every metadata field set
+func exportTestEngine(t *testing.T) *SemanticEngine {
+	t.Helper()
+	engine := newSemanticEngine(NewLocalClient(64), ProviderLocal, "local")
+
+	var chunks []Chunk
+	for _, c := range []Chunk{
+		{ChapterID: "hooks", ChapterName: "Hooks", Section: "State", SectionID: "state", Content: "useState keeps component state", Locale: "en"},
+		{ChapterID: "hooks", ChapterName: "Hooks", Section: "Effects", SectionID: "effects", Content: "useEffect runs after render", Locale: "en"},
+		{ChapterID: "hooks", ChapterName: "Hooks", Section: "Estado", SectionID: "estado", Content: "useState guarda el estado", Locale: "es"},
+	} {
+		c.Breadcrumb = c.ChapterName + " > " + c.Section
+		c.ChapterOrder, c.ContentType, c.Tags = 3, "prose", []string{"react"}
+		c.ID = ChunkID(c.Locale, c.ChapterID, c.Section, c.EmbedText())
+		chunks = append(chunks, c)
+	}
+	if _, err := engine.IndexChunks(context.Background(), chunks, nil); err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+// export returns an export of engine in format
+func export(t *testing.T, engine *SemanticEngine, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := engine.Export(&buf, format); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	source := exportTestEngine(t)
+	want := source.allChunks()
+
+	for _, format := range []string{ExportJSONL, ExportBinary} {
+		t.Run(format, func(t *testing.T) {
+			header, chunks, err := ReadExport(bytes.NewReader(export(t, source, format)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Provider != ProviderLocal || header.Model != "local" || header.Dimensions != 64 || header.ChunkCount != len(want) {
+				t.Errorf("header = %+v", header)
+			}
+
+			target := newSemanticEngine(NewLocalClient(64), ProviderLocal, "local")
+			if err := target.Import(header, chunks); err != nil {
+				t.Fatal(err)
+			}
+			if info := target.IndexInfo(); info != source.IndexInfo() {
+				t.Errorf("imported index info %v, want %v", info, source.IndexInfo())
+			}
+
+			got := target.allChunks()
+			if len(got) != len(want) {
+				t.Fatalf("imported %d chunks, want %d", len(got), len(want))
+			}
+			for i := range want {
+				g, w := got[i], want[i]
+				if g.ID != w.ID || g.Section != w.Section || g.SectionID != w.SectionID || g.Breadcrumb != w.Breadcrumb ||
+					g.Content != w.Content || g.Locale != w.Locale || g.ChapterOrder != w.ChapterOrder ||
+					g.ContentType != w.ContentType || !slices.Equal(g.Tags, w.Tags) {
+					t.Errorf("chunk %d = %+v, want %+v", i, g, w)
+				}
+				if sim := g.Embedding.Dot(w.Embedding); sim < 0.9999 {
+					t.Errorf("chunk %s vector changed, similarity %.5f", w.ID, sim)
+				}
+			}
+
+			ctx := context.Background()
+			opts := SearchOptions{TopK: 2, Locale: "en"}
+			before, err := source.Search(ctx, "component state", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, err := target.Search(ctx, "component state", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(contents(after), contents(before)) {
+				t.Errorf("search after import = %v, want %v", contents(after), contents(before))
+			}
+		})
+	}
+}
+
+func TestReadExportRejectsTruncated(t *testing.T) {
+	source := exportTestEngine(t)
+
+	for _, format := range []string{ExportJSONL, ExportBinary} {
+		data := export(t, source, format)
+		cuts := map[string]int{
+			"mid chunk":  len(data) - 10,
+			"mid header": 12,
+			"empty":      0,
+		}
+		if format == ExportJSONL {
+			// Drop the whole last line, which still parses but misses a chunk
+			cuts["last chunk"] = bytes.LastIndexByte(data[:len(data)-1], '\n') + 1
+		}
+		for name, cut := range cuts {
+			if _, _, err := ReadExport(bytes.NewReader(data[:cut])); err == nil {
+				t.Errorf("%s, cut %s: no error", format, name)
+			}
+		}
+	}
+}
+
+func TestReadExportRejectsDimensionMismatch(t *testing.T) {
+	source := exportTestEngine(t)
+
+	// The header declares more dimensions than the vectors have
+	for _, format := range []string{ExportJSONL, ExportBinary} {
+		data := bytes.Replace(export(t, source, format), []byte(`"dimensions":64`), []byte(`"dimensions":65`), 1)
+		if _, _, err := ReadExport(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: header with the wrong dimensions accepted", format)
+		}
+	}
+
+	// One chunk has a shorter vector than the rest: drop its first value
+	lines := strings.Split(strings.TrimSuffix(string(export(t, source, ExportJSONL)), "\n"), "\n")
+	start := strings.Index(lines[2], `"embedding":[`) + len(`"embedding":[`)
+	comma := strings.Index(lines[2][start:], ",")
+	lines[2] = lines[2][:start] + lines[2][start+comma+1:]
+	_, _, err := ReadExport(strings.NewReader(strings.Join(lines, "\n")))
+	if err == nil || !strings.Contains(err.Error(), "63 dimensions") {
+		t.Errorf("short vector: got %v, want a dimensions error", err)
+	}
+}
+
+func TestImportRejectsOtherModel(t *testing.T) {
+	source := exportTestEngine(t)
+	header, chunks, err := ReadExport(bytes.NewReader(export(t, source, ExportBinary)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := newSemanticEngine(NewLocalClient(64), ProviderLocal, "other")
+	var mismatch *MismatchError
+	if err := target.Import(header, chunks); !errors.As(err, &mismatch) {
+		t.Fatalf("got %v, want a *MismatchError", err)
+	}
+	if target.IsIndexed() {
+		t.Error("rejected import replaced the index")
+	}
+}
+
+func TestExportIndexFile(t *testing.T) {
+	source := exportTestEngine(t)
+	path := filepath.Join(t.TempDir(), "index.json.gz")
+	if err := source.SaveIndex(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// The saved file exports the same chunks as the engine, without a provider
+	var buf bytes.Buffer
+	header, err := ExportIndexFile(path, &buf, ExportBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Provider != ProviderLocal || header.Model != "local" || header.Dimensions != 64 {
+		t.Errorf("header = %+v", header)
+	}
+	_, got, err := ReadExport(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, want, err := ReadExport(bytes.NewReader(export(t, source, ExportBinary)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("exported %d chunks, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Embedding.Dot(want[i].Embedding) < 0.9999 {
+			t.Errorf("chunk %d = %s, want %s", i, got[i].ID, want[i].ID)
+		}
+	}
+
+	if _, err := ExportIndexFile(filepath.Join(t.TempDir(), "missing.json.gz"), &buf, ExportJSONL); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: got %v, want os.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,418 @@
+package embeddings
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+)
+
+// ============================================
+// VECTOR INDEXES
+// ============================================
+
+// VectorIndex finds the stored vectors most similar to a query. Vectors are
+// identified by their insertion position. When accept is not nil, only
+// positions it accepts are returned.
+type VectorIndex interface {
+	Add(vector Vector)
+	Search(query Vector, k int, accept func(pos int) bool) []Neighbor
+	Len() int
+}
+
+// Neighbor is a search hit: the position of a stored vector and its cosine
+// similarity to the query
+type Neighbor struct {
+	Pos   int
+	Score float64
+}
+
+// Index kinds
+const (
+	IndexFlat = "flat" // exact brute-force search
+	IndexHNSW = "hnsw" // approximate search over a navigable small-world graph
+)
+
+// Vector quantization modes
+const (
+	QuantizationNone = "none" // float32 vectors
+	QuantizationInt8 = "int8" // int8 scalar quantization
+)
+
+// IndexConfig selects and tunes the index used by vector stores
+type IndexConfig struct {
+	Kind           string
+	M              int    // HNSW links per node (twice as many on the bottom layer)
+	EfConstruction int    // HNSW candidate list size while inserting
+	EfSearch       int    // HNSW candidate list size while searching
+	Quantization   string // how stored vectors are kept in memory and on disk
+	Rescore        int    // with int8, rescore Rescore*k candidates with kept float32 vectors; 0 keeps only int8
+}
+
+// DefaultIndexConfig returns an exact flat index with sensible HNSW defaults
+func DefaultIndexConfig() IndexConfig {
+	return IndexConfig{
+		Kind:           IndexFlat,
+		M:              16,
+		EfConstruction: 200,
+		EfSearch:       64,
+		Quantization:   QuantizationNone,
+	}
+}
+
+// IndexConfigFromEnv reads VECTOR_INDEX, HNSW_M, HNSW_EF_CONSTRUCTION,
+// HNSW_EF_SEARCH, VECTOR_QUANTIZATION and VECTOR_RESCORE on top of the defaults
+func IndexConfigFromEnv() IndexConfig {
+	cfg := DefaultIndexConfig()
+	if kind := os.Getenv("VECTOR_INDEX"); kind != "" {
+		cfg.Kind = kind
+	}
+	cfg.M = envInt("HNSW_M", cfg.M)
+	cfg.EfConstruction = envInt("HNSW_EF_CONSTRUCTION", cfg.EfConstruction)
+	cfg.EfSearch = envInt("HNSW_EF_SEARCH", cfg.EfSearch)
+	if quantization := os.Getenv("VECTOR_QUANTIZATION"); quantization != "" {
+		cfg.Quantization = quantization
+	}
+	cfg.Rescore = max(0, envInt("VECTOR_RESCORE", cfg.Rescore))
+	return cfg
+}
+
+// rescores reports whether int8 search results are rescored with float32
+// vectors kept alongside the quantized ones
+func (c IndexConfig) rescores() bool {
+	return c.Quantization == QuantizationInt8 && c.Rescore > 0
+}
+
+// newIndex creates an empty index of the configured kind
+func (c IndexConfig) newIndex() VectorIndex {
+	if c.Kind == IndexHNSW {
+		return newHNSWIndex(c)
+	}
+	return &flatIndex{}
+}
+
+// ============================================
+// FLAT INDEX
+// ============================================
+
+// flatIndex scores every vector and keeps the best k in a heap. Quantized
+// vectors are compared in integer arithmetic when the query is quantized too.
+type flatIndex struct {
+	vectors []Vector
+}
+
+func (f *flatIndex) Add(vector Vector) {
+	f.vectors = append(f.vectors, vector)
+}
+
+func (f *flatIndex) Len() int {
+	return len(f.vectors)
+}
+
+func (f *flatIndex) Search(query Vector, k int, accept func(pos int) bool) []Neighbor {
+	top := newTopK(k)
+	for i, vector := range f.vectors {
+		if accept == nil || accept(i) {
+			top.offer(Neighbor{Pos: i, Score: vector.Dot(query)})
+		}
+	}
+	return top.sorted()
+}
+
+// ============================================
+// HNSW INDEX
+// ============================================
+
+// hnswIndex is a Hierarchical Navigable Small World graph (Malkov & Yashunin).
+// Quantized vectors are compared against the query as given; VectorStore
+// rescores the results with float32 vectors when Rescore is set.
+type hnswIndex struct {
+	m              int
+	maxLinks0      int
+	efConstruction int
+	efSearch       int
+	levelMult      float64
+	rng            *rand.Rand
+
+	vectors  []Vector
+	links    [][][]int // links[node][layer]
+	entry    int
+	maxLevel int
+}
+
+func newHNSWIndex(cfg IndexConfig) *hnswIndex {
+	m := max(cfg.M, 2)
+	return &hnswIndex{
+		m:              m,
+		maxLinks0:      2 * m,
+		efConstruction: max(cfg.EfConstruction, m),
+		efSearch:       max(cfg.EfSearch, 1),
+		levelMult:      1 / math.Log(float64(m)),
+		// Fixed seed so the same chunks always produce the same graph
+		rng:   rand.New(rand.NewSource(1)),
+		entry: -1,
+	}
+}
+
+func (h *hnswIndex) Len() int {
+	return len(h.vectors)
+}
+
+func (h *hnswIndex) Add(vector Vector) {
+	v := Vector{values: vector.Float32()}
+	node := len(h.vectors)
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+
+	h.vectors = append(h.vectors, vector)
+	h.links = append(h.links, make([][]int, level+1))
+
+	if h.entry < 0 {
+		h.entry, h.maxLevel = node, level
+		return
+	}
+
+	// Descend greedily to the node's top layer, then link it on every layer below
+	ep := Neighbor{Pos: h.entry, Score: h.similarity(v, h.entry)}
+	for layer := h.maxLevel; layer > level; layer-- {
+		ep = h.greedy(v, ep, layer)
+	}
+
+	entries := []Neighbor{ep}
+	for layer := min(level, h.maxLevel); layer >= 0; layer-- {
+		candidates := h.searchLayer(v, entries, h.efConstruction, layer, nil)
+		neighbors := h.selectNeighbors(candidates, h.m)
+
+		links := make([]int, len(neighbors))
+		for i, n := range neighbors {
+			links[i] = n.Pos
+			h.connect(n.Pos, node, layer)
+		}
+		h.links[node][layer] = links
+
+		entries = candidates
+	}
+
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = node, level
+	}
+}
+
+// Search walks the whole graph regardless of accept, so rejected nodes still
+// lead to accepted ones; only the results are filtered.
+func (h *hnswIndex) Search(query Vector, k int, accept func(pos int) bool) []Neighbor {
+	if h.entry < 0 || k <= 0 {
+		return nil
+	}
+
+	q := Vector{values: query.Float32()}
+	ep := Neighbor{Pos: h.entry, Score: h.similarity(q, h.entry)}
+	for layer := h.maxLevel; layer > 0; layer-- {
+		ep = h.greedy(q, ep, layer)
+	}
+
+	results := h.searchLayer(q, []Neighbor{ep}, max(h.efSearch, k), 0, accept)
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+func (h *hnswIndex) similarity(q Vector, node int) float64 {
+	return h.vectors[node].Dot(q)
+}
+
+func (h *hnswIndex) maxLinks(layer int) int {
+	if layer == 0 {
+		return h.maxLinks0
+	}
+	return h.m
+}
+
+// greedy walks a layer towards q, stopping at a local optimum
+func (h *hnswIndex) greedy(q Vector, ep Neighbor, layer int) Neighbor {
+	for changed := true; changed; {
+		changed = false
+		for _, n := range h.links[ep.Pos][layer] {
+			if score := h.similarity(q, n); score > ep.Score {
+				ep = Neighbor{Pos: n, Score: score}
+				changed = true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer returns up to ef accepted nodes of a layer closest to q,
+// sorted by decreasing similarity
+func (h *hnswIndex) searchLayer(q Vector, entries []Neighbor, ef, layer int, accept func(pos int) bool) []Neighbor {
+	visited := make(map[int]bool, ef*4)
+	candidates := &neighborHeap{max: true}
+	results := &neighborHeap{}
+
+	for _, e := range entries {
+		visited[e.Pos] = true
+		heap.Push(candidates, e)
+		if accept == nil || accept(e.Pos) {
+			heap.Push(results, e)
+			if results.Len() > ef {
+				heap.Pop(results)
+			}
+		}
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(Neighbor)
+		if results.Len() >= ef && c.Score < results.items[0].Score {
+			break // every remaining candidate is worse than the worst result
+		}
+
+		for _, n := range h.links[c.Pos][layer] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+
+			score := h.similarity(q, n)
+			if results.Len() < ef || score > results.items[0].Score {
+				heap.Push(candidates, Neighbor{Pos: n, Score: score})
+				if accept == nil || accept(n) {
+					heap.Push(results, Neighbor{Pos: n, Score: score})
+					if results.Len() > ef {
+						heap.Pop(results)
+					}
+				}
+			}
+		}
+	}
+
+	out := make([]Neighbor, len(results.items))
+	copy(out, results.items)
+	sortNeighbors(out)
+	return out
+}
+
+// selectNeighbors picks up to m of the candidates (sorted by decreasing
+// similarity to the base node). A candidate is preferred when it is closer to
+// the base than to every neighbor already picked, which keeps links spread
+// across clusters; the closest skipped candidates fill any remaining slots.
+func (h *hnswIndex) selectNeighbors(candidates []Neighbor, m int) []Neighbor {
+	if len(candidates) <= m {
+		return candidates
+	}
+
+	selected := make([]Neighbor, 0, m)
+	var skipped []Neighbor
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		diverse := true
+		for _, s := range selected {
+			if h.vectors[c.Pos].Dot(h.vectors[s.Pos]) > c.Score {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c)
+		} else {
+			skipped = append(skipped, c)
+		}
+	}
+
+	for _, c := range skipped {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, c)
+	}
+	return selected
+}
+
+// connect links node to target, pruning node's links when over capacity
+func (h *hnswIndex) connect(node, target, layer int) {
+	links := append(h.links[node][layer], target)
+
+	if limit := h.maxLinks(layer); len(links) > limit {
+		candidates := make([]Neighbor, len(links))
+		for i, l := range links {
+			candidates[i] = Neighbor{Pos: l, Score: h.vectors[node].Dot(h.vectors[l])}
+		}
+		sortNeighbors(candidates)
+
+		links = links[:0]
+		for _, n := range h.selectNeighbors(candidates, limit) {
+			links = append(links, n.Pos)
+		}
+	}
+
+	h.links[node][layer] = links
+}
+
+// ============================================
+// HELPERS
+// ============================================
+
+// neighborHeap is a min-heap by score, or a max-heap when max is set
+type neighborHeap struct {
+	items []Neighbor
+	max   bool
+}
+
+func (h *neighborHeap) Len() int { return len(h.items) }
+func (h *neighborHeap) Less(i, j int) bool {
+	if h.max {
+		return h.items[i].Score > h.items[j].Score
+	}
+	return h.items[i].Score < h.items[j].Score
+}
+func (h *neighborHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *neighborHeap) Push(x any)    { h.items = append(h.items, x.(Neighbor)) }
+func (h *neighborHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// topK keeps the k highest-scoring neighbors offered to it
+type topK struct {
+	k    int
+	heap neighborHeap
+}
+
+func newTopK(k int) *topK {
+	return &topK{k: k, heap: neighborHeap{items: make([]Neighbor, 0, max(k, 0))}}
+}
+
+func (t *topK) offer(n Neighbor) {
+	if t.k <= 0 {
+		return
+	}
+	if t.heap.Len() < t.k {
+		heap.Push(&t.heap, n)
+		return
+	}
+	if n.Score > t.heap.items[0].Score {
+		t.heap.items[0] = n
+		heap.Fix(&t.heap, 0)
+	}
+}
+
+// sorted returns the kept neighbors by decreasing score
+func (t *topK) sorted() []Neighbor {
+	out := make([]Neighbor, len(t.heap.items))
+	copy(out, t.heap.items)
+	sortNeighbors(out)
+	return out
+}
+
+// sortNeighbors orders by decreasing score, then by position for stable output
+func sortNeighbors(neighbors []Neighbor) {
+	sort.Slice(neighbors, func(i, j int) bool {
+		if neighbors[i].Score != neighbors[j].Score {
+			return neighbors[i].Score > neighbors[j].Score
+		}
+		return neighbors[i].Pos < neighbors[j].Pos
+	})
+}
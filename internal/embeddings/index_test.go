@@ -0,0 +1,213 @@
+package embeddings
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// syntheticCorpus returns n clustered random vectors, which resembles real
+// embeddings (topics) better than uniform noise, plus queries drawn near the
+// same clusters
+func syntheticCorpus(n, queries, dims int) ([][]float64, []Vector) {
+	rng := rand.New(rand.NewSource(42))
+
+	centers := make([][]float64, 50)
+	for i := range centers {
+		centers[i] = make([]float64, dims)
+		for d := range centers[i] {
+			centers[i][d] = rng.NormFloat64()
+		}
+	}
+
+	sample := func() []float64 {
+		center := centers[rng.Intn(len(centers))]
+		v := make([]float64, dims)
+		for d := range v {
+			v[d] = center[d] + 0.6*rng.NormFloat64()
+		}
+		return v
+	}
+
+	vectors := make([][]float64, n)
+	for i := range vectors {
+		vectors[i] = sample()
+	}
+	qs := make([]Vector, queries)
+	for i := range qs {
+		qs[i] = NewVector(sample())
+	}
+	return vectors, qs
+}
+
+func buildIndex(cfg IndexConfig, vectors [][]float64) VectorIndex {
+	index := cfg.newIndex()
+	for _, v := range vectors {
+		vector := NewVector(v)
+		if cfg.Quantization == QuantizationInt8 {
+			vector = vector.Quantize()
+		}
+		index.Add(vector)
+	}
+	return index
+}
+
+// recallAt returns the fraction of the exact top k found by the index
+func recallAt(k int, exact, index VectorIndex, queries []Vector) float64 {
+	found, total := 0, 0
+	for _, q := range queries {
+		truth := make(map[int]bool)
+		for _, n := range exact.Search(q, k, nil) {
+			truth[n.Pos] = true
+		}
+		for _, n := range index.Search(q, k, nil) {
+			if truth[n.Pos] {
+				found++
+			}
+		}
+		total += len(truth)
+	}
+	return float64(found) / float64(total)
+}
+
+func TestHNSWRecall(t *testing.T) {
+	vectors, queries := syntheticCorpus(2000, 100, 64)
+
+	exact := buildIndex(IndexConfig{Kind: IndexFlat}, vectors)
+	hnsw := buildIndex(DefaultIndexConfig().withKind(IndexHNSW), vectors)
+
+	if recall := recallAt(10, exact, hnsw, queries); recall < 0.95 {
+		t.Fatalf("HNSW recall@10 = %.3f, want >= 0.95", recall)
+	}
+}
+
+func TestInt8RankingStable(t *testing.T) {
+	vectors, queries := syntheticCorpus(2000, 100, 64)
+
+	exact := buildIndex(IndexConfig{Kind: IndexFlat}, vectors)
+	cfg := DefaultIndexConfig()
+	cfg.Quantization = QuantizationInt8
+	quantized := buildIndex(cfg, vectors)
+
+	if recall := recallAt(10, exact, quantized, queries); recall < 0.95 {
+		t.Fatalf("int8 recall@10 = %.3f, want >= 0.95", recall)
+	}
+}
+
+func TestInt8Rescore(t *testing.T) {
+	vectors, queries := syntheticCorpus(1000, 50, 64)
+	store := func(cfg IndexConfig) *VectorStore {
+		s := NewVectorStoreWithIndex(cfg)
+		for i, v := range vectors {
+			s.add(Chunk{ID: fmt.Sprint(i), Locale: "en", Embedding: NewVector(v)})
+		}
+		return s
+	}
+	recall := func(exact, s *VectorStore) (float64, bool) {
+		found, total, exactScores := 0, 0, true
+		for _, q := range queries {
+			truth := make(map[string]float64)
+			for _, c := range exact.candidates(q, SearchOptions{TopK: 10}) {
+				truth[c.chunk.ID] = c.score
+			}
+			for _, c := range s.candidates(q, SearchOptions{TopK: 10}) {
+				if score, ok := truth[c.chunk.ID]; ok {
+					found++
+					exactScores = exactScores && math.Abs(score-c.score) < 1e-6
+				}
+			}
+			total += len(truth)
+		}
+		return float64(found) / float64(total), exactScores
+	}
+
+	exact := store(DefaultIndexConfig())
+	cfg := DefaultIndexConfig()
+	cfg.Quantization = QuantizationInt8
+
+	for _, kind := range []string{IndexFlat, IndexHNSW} {
+		cfg.Kind, cfg.Rescore = kind, 4
+		rescored := store(cfg)
+		if rescored.chunks[0].Embedding.Quantized() || !rescored.config.rescores() {
+			t.Fatalf("%s: rescoring needs the float32 vectors kept", kind)
+		}
+		r, exactScores := recall(exact, rescored)
+		if r < 0.97 || !exactScores {
+			t.Errorf("%s: rescored recall@10 = %.3f, exact scores %v; want >= 0.97 and exact scores", kind, r, exactScores)
+		}
+
+		cfg.Rescore = 0
+		if quantized := store(cfg); !quantized.chunks[0].Embedding.Quantized() {
+			t.Errorf("%s: without rescoring only the int8 codes should be kept", kind)
+		}
+	}
+}
+
+func TestFlatSearchOrder(t *testing.T) {
+	index := buildIndex(IndexConfig{Kind: IndexFlat}, [][]float64{{1, 0}, {0, 1}, {1, 1}, {-1, 0}})
+
+	got := index.Search(NewVector([]float64{1, 0.1}), 3, nil)
+	want := []int{0, 2, 1}
+	if len(got) != len(want) {
+		t.Fatalf("got %d results, want %d", len(got), len(want))
+	}
+	for i, n := range got {
+		if n.Pos != want[i] {
+			t.Fatalf("result %d = %d, want %d (%v)", i, n.Pos, want[i], got)
+		}
+	}
+}
+
+// BenchmarkVectorSearch compares exact search with int8 quantized search and
+// HNSW at several efSearch values. Each sub-benchmark reports its recall@10 against the exact results.
+//
+//	go test ./internal/embeddings -run '^$' -bench VectorSearch
+func BenchmarkVectorSearch(b *testing.B) {
+	const k = 10
+	vectors, queries := syntheticCorpus(10000, 200, 256)
+
+	start := time.Now()
+	exact := buildIndex(IndexConfig{Kind: IndexFlat}, vectors)
+	b.Logf("flat: built %d vectors in %v", len(vectors), time.Since(start))
+
+	b.Run("flat", func(b *testing.B) {
+		benchmarkSearch(b, exact, queries, k)
+		b.ReportMetric(1, "recall@10")
+	})
+
+	cfg := DefaultIndexConfig()
+	cfg.Quantization = QuantizationInt8
+	quantized := buildIndex(cfg, vectors)
+	b.Run("flat/int8", func(b *testing.B) {
+		benchmarkSearch(b, quantized, queries, k)
+		b.ReportMetric(recallAt(k, exact, quantized, queries), "recall@10")
+	})
+
+	start = time.Now()
+	cfg = DefaultIndexConfig().withKind(IndexHNSW)
+	graph := buildIndex(cfg, vectors).(*hnswIndex)
+	b.Logf("hnsw: built %d vectors in %v (M=%d, efConstruction=%d)", len(vectors), time.Since(start), cfg.M, cfg.EfConstruction)
+
+	for _, ef := range []int{16, 64, 256} {
+		b.Run(fmt.Sprintf("hnsw/ef=%d", ef), func(b *testing.B) {
+			graph.efSearch = ef
+			benchmarkSearch(b, graph, queries, k)
+			b.ReportMetric(recallAt(k, exact, graph, queries), "recall@10")
+		})
+	}
+}
+
+func benchmarkSearch(b *testing.B, index VectorIndex, queries []Vector, k int) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.Search(queries[i%len(queries)], k, nil)
+	}
+	b.StopTimer()
+}
+
+func (c IndexConfig) withKind(kind string) IndexConfig {
+	c.Kind = kind
+	return c
+}
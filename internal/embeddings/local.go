@@ -0,0 +1,165 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// ============================================
+// LOCAL CLIENT
+// ============================================
+
+// LocalClient generates embeddings in-process with feature hashing: words,
+// word pairs and character n-grams are hashed into a fixed number of signed
+// buckets and weighted by sublinear term frequency. It needs no network or
+// model download, so it works on air-gapped machines and in CI. Rankings are
+// lexical rather than truly semantic, but character n-grams still match
+// inflections and es/en cognates ("arquitectura" / "architecture").
+type LocalClient struct {
+	dimensions int
+}
+
+// NewLocalClient creates a local client. dimensions <= 0 uses
+// LOCAL_EMBEDDING_DIMENSIONS or 512.
+func NewLocalClient(dimensions int) *LocalClient {
+	if dimensions <= 0 {
+		dimensions = envInt("LOCAL_EMBEDDING_DIMENSIONS", 512)
+	}
+	if dimensions <= 0 {
+		dimensions = 512
+	}
+	return &LocalClient{dimensions: dimensions}
+}
+
+// Model returns the embedding model name. The dimension is part of the name
+// because vectors of different sizes are not comparable.
+func (c *LocalClient) Model() string {
+	return fmt.Sprintf("hashing-v1-%d", c.dimensions)
+}
+
+func (c *LocalClient) Embed(ctx context.Context, text string) ([]float64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.embed(text), nil
+}
+
+func (c *LocalClient) EmbedBatch(ctx context.Context, texts []string) ([][]float64, error) {
+	embeddings := make([][]float64, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		embeddings[i] = c.embed(text)
+	}
+	return embeddings, nil
+}
+
+// Feature weights: whole words matter most, n-grams add fuzzy matching
+const (
+	localWordWeight   = 1.0
+	localBigramWeight = 0.5
+	localNgramWeight  = 0.25
+	localNgramSize    = 4
+)
+
+func (c *LocalClient) embed(text string) []float64 {
+	counts := make(map[string]float64)
+
+	words := localTokens(text)
+	for i, word := range words {
+		counts["w:"+word] += localWordWeight
+		if i > 0 {
+			counts["b:"+words[i-1]+" "+word] += localBigramWeight
+		}
+
+		padded := []rune("^" + word + "$")
+		for j := 0; j+localNgramSize <= len(padded); j++ {
+			counts["n:"+string(padded[j:j+localNgramSize])] += localNgramWeight
+		}
+	}
+
+	vector := make([]float64, c.dimensions)
+	for feature, count := range counts {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+
+		// The top bit picks the sign so collisions tend to cancel out
+		sign := 1.0
+		if sum>>63 == 1 {
+			sign = -1.0
+		}
+		vector[sum%uint64(c.dimensions)] += sign * (1 + math.Log(count))
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vector {
+			vector[i] /= norm
+		}
+	}
+
+	return vector
+}
+
+// localTokens lowercases, strips accents, splits on non-alphanumerics and
+// drops stopwords and very short tokens
+func localTokens(text string) []string {
+	var tokens []string
+	for _, field := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		word := foldAccents(field)
+		if len(word) < 2 || localStopwords[word] {
+			continue
+		}
+		tokens = append(tokens, stemLight(word))
+	}
+	return tokens
+}
+
+var accentFolds = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u",
+	"à", "a", "è", "e", "ì", "i", "ò", "o", "ù", "u",
+	"ä", "a", "ë", "e", "ï", "i", "ö", "o", "ü", "u",
+	"ñ", "n", "ç", "c",
+)
+
+func foldAccents(word string) string {
+	return accentFolds.Replace(word)
+}
+
+// stemLight strips the plural "s" shared by Spanish and English; character
+// n-grams take care of the remaining inflections
+func stemLight(word string) string {
+	if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// localStopwords are frequent Spanish and English words with little meaning
+var localStopwords = func() map[string]bool {
+	words := strings.Fields(`
+		a al algo como con de del el ella ellos en entre era es esa ese eso esta este esto
+		estos fue ha hay la las le les lo los mas me mi muy no nos o para pero por que se
+		ser si sin sobre son su sus tambien te tiene todo tu un una uno unos y ya yo
+		an and are as at be been but by can do for from has have he how if in into is it
+		its just more not of on or our so than that the their them then there these they
+		this to was we were what when which who will with you your
+	`)
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}()
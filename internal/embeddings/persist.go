@@ -0,0 +1,174 @@
+package embeddings
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// indexFileVersion is bumped whenever the on-disk index layout changes.
+// Version 2 stores vectors as base64 float32 or int8 instead of JSON arrays;
+// version 1 files are still read.
+const indexFileVersion = 2
+
+// IndexMetadata describes how a persisted index was built
+type IndexMetadata struct {
+	Version    int       `json:"version"`
+	Provider   Provider  `json:"provider"`
+	Model      string    `json:"model"`
+	Dimensions int       `json:"dimensions"`
+	BuiltAt    time.Time `json:"builtAt"`
+	ChunkCount int       `json:"chunkCount"`
+	Checksum   string    `json:"checksum"` // SHA-256 of the raw chunks JSON
+}
+
+// indexFile is the gzip-compressed JSON document written to disk
+type indexFile struct {
+	IndexMetadata
+	Chunks json.RawMessage `json:"chunks"`
+}
+
+// SaveIndex writes the current index to path, replacing any previous file atomically
+func (e *SemanticEngine) SaveIndex(path string) error {
+	e.mu.RLock()
+	info, builtAt := e.info, e.builtAt
+	e.mu.RUnlock()
+
+	chunks := e.allChunks()
+	if len(chunks) == 0 {
+		return fmt.Errorf("index not built, nothing to save")
+	}
+
+	chunksJSON, err := json.Marshal(chunks)
+	if err != nil {
+		return fmt.Errorf("error encoding chunks: %w", err)
+	}
+
+	sum := sha256.Sum256(chunksJSON)
+	file := indexFile{
+		IndexMetadata: IndexMetadata{
+			Version:    indexFileVersion,
+			Provider:   info.Provider,
+			Model:      info.Model,
+			Dimensions: dimensionsOf(chunks),
+			BuiltAt:    builtAt,
+			ChunkCount: len(chunks),
+			Checksum:   hex.EncodeToString(sum[:]),
+		},
+		Chunks: chunksJSON,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating index directory: %w", err)
+	}
+
+	// Write to a temp file in the same directory, then rename over the target
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating index file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	gz := gzip.NewWriter(tmp)
+	if err := json.NewEncoder(gz).Encode(file); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing index: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing index: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing index file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadIndex replaces the current index with the one stored at path. The file
+// must pass integrity checks. An index built with another provider or model
+// is still loaded so status can report it, but CheckIndex fails and searches
+// are rejected until it is rebuilt.
+func (e *SemanticEngine) LoadIndex(path string) (*IndexMetadata, error) {
+	meta, chunks, err := readIndexFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the stores before taking the locks, graph indexes take a while
+	stores := e.groupByLocale(chunks)
+
+	e.indexMutex.Lock()
+	defer e.indexMutex.Unlock()
+
+	// The loaded file replaces every locale, not just the ones it contains
+	e.mu.Lock()
+	e.indexes = stores
+	e.info = IndexInfo{Provider: meta.Provider, Model: meta.Model, Dimensions: meta.Dimensions}
+	e.builtAt = meta.BuiltAt
+	e.mu.Unlock()
+
+	return meta, nil
+}
+
+// readIndexFile reads the index stored at path and checks its integrity,
+// without needing an engine or a provider
+func readIndexFile(path string) (*IndexMetadata, []Chunk, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("corrupt index file %s: %w", path, err)
+	}
+	defer gz.Close()
+
+	var file indexFile
+	if err := json.NewDecoder(gz).Decode(&file); err != nil {
+		return nil, nil, fmt.Errorf("corrupt index file %s: %w", path, err)
+	}
+
+	meta := file.IndexMetadata
+	if meta.Version < 1 || meta.Version > indexFileVersion {
+		return nil, nil, fmt.Errorf("unsupported index version %d (expected %d)", meta.Version, indexFileVersion)
+	}
+
+	sum := sha256.Sum256(file.Chunks)
+	if hex.EncodeToString(sum[:]) != meta.Checksum {
+		return nil, nil, fmt.Errorf("index checksum mismatch, file is corrupt")
+	}
+
+	var chunks []Chunk
+	if err := json.Unmarshal(file.Chunks, &chunks); err != nil {
+		return nil, nil, fmt.Errorf("corrupt index chunks: %w", err)
+	}
+	if len(chunks) != meta.ChunkCount {
+		return nil, nil, fmt.Errorf("index declares %d chunks but contains %d", meta.ChunkCount, len(chunks))
+	}
+	for _, chunk := range chunks {
+		if chunk.Embedding.Len() != meta.Dimensions {
+			return nil, nil, fmt.Errorf("chunk %s has %d dimensions, index declares %d", chunk.ID, chunk.Embedding.Len(), meta.Dimensions)
+		}
+	}
+	return &meta, chunks, nil
+}
+
+// dimensionsOf returns the embedding size of the first chunk
+func dimensionsOf(chunks []Chunk) int {
+	if len(chunks) == 0 {
+		return 0
+	}
+	return chunks[0].Embedding.Len()
+}
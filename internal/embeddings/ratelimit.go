@@ -0,0 +1,138 @@
+package embeddings
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Alan-TheGentleman/gentleman-book-mcp/internal/book"
+)
+
+// ============================================
+// RATE LIMITING
+// ============================================
+
+// tokenBucket allows up to capacity units per minute, refilled continuously
+type tokenBucket struct {
+	capacity float64
+	perSec   float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a bucket for perMinute units, or nil (no limit) when
+// perMinute is not positive
+func newTokenBucket(perMinute int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		perSec:   float64(perMinute) / 60,
+		tokens:   float64(perMinute),
+		last:     time.Now(),
+	}
+}
+
+// take blocks until n units are available or ctx is done. Requests larger
+// than the bucket wait for a full bucket instead of forever.
+func (b *tokenBucket) take(ctx context.Context, n int) error {
+	if b == nil {
+		return nil
+	}
+
+	for {
+		wait := b.reserve(min(float64(n), b.capacity))
+		if wait == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// reserve takes n units if available and returns 0, or returns how long to
+// wait until they are
+func (b *tokenBucket) reserve(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.perSec)
+	b.last = now
+
+	if b.tokens >= n {
+		b.tokens -= n
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.perSec * float64(time.Second))
+}
+
+// rateLimiter keeps a client under requests-per-minute and tokens-per-minute limits
+type rateLimiter struct {
+	requests *tokenBucket
+	tokens   *tokenBucket
+}
+
+func newRateLimiter(rpm, tpm int) *rateLimiter {
+	return &rateLimiter{requests: newTokenBucket(rpm), tokens: newTokenBucket(tpm)}
+}
+
+// wait blocks until one request of the given estimated tokens may be sent
+func (l *rateLimiter) wait(ctx context.Context, tokens int) error {
+	if l == nil {
+		return nil
+	}
+	if err := l.requests.take(ctx, 1); err != nil {
+		return err
+	}
+	return l.tokens.take(ctx, tokens)
+}
+
+// splitByTokens groups texts into consecutive batches of at most maxTokens
+// estimated tokens. A text larger than maxTokens gets a batch of its own.
+func splitByTokens(texts []string, maxTokens int) [][]string {
+	if maxTokens <= 0 {
+		return [][]string{texts}
+	}
+
+	var batches [][]string
+	start, tokens := 0, 0
+	for i, text := range texts {
+		n := book.EstimateTokens(text)
+		if i > start && tokens+n > maxTokens {
+			batches = append(batches, texts[start:i])
+			start, tokens = i, 0
+		}
+		tokens += n
+	}
+	if start < len(texts) {
+		batches = append(batches, texts[start:])
+	}
+	return batches
+}
+
+// parseRetryAfter reads how long the server asks to wait, from the
+// retry-after-ms header sent by OpenAI or the standard Retry-After header
+// (seconds or an HTTP date). It returns 0 when neither is usable.
+func parseRetryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
@@ -0,0 +1,124 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// APIError is a non-2xx response from an embeddings API
+type APIError struct {
+	Provider   Provider
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // wait requested by the server, 0 if none
+}
+
+// RateLimited reports whether the server rejected the request for exceeding a rate limit
+func (e *APIError) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API error (HTTP %d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// EmbedFailure describes a single text that could not be embedded
+type EmbedFailure struct {
+	Index int
+	Err   error
+}
+
+// BatchError is returned by EmbedBatch when some texts failed. The returned
+// embeddings are still valid for every index not listed in Failures.
+type BatchError struct {
+	Failures []EmbedFailure
+}
+
+func (e *BatchError) Error() string {
+	if len(e.Failures) == 1 {
+		return fmt.Sprintf("1 text failed to embed: %v", e.Failures[0].Err)
+	}
+	return fmt.Sprintf("%d texts failed to embed (first error: %v)", len(e.Failures), e.Failures[0].Err)
+}
+
+// maxRetryAfter caps the wait a server can ask for before a retry
+const maxRetryAfter = 2 * time.Minute
+
+// retryPolicy controls how transient errors are retried
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+type noRetryKey struct{}
+
+// withoutRetries marks ctx so that requests made with it fail fast, e.g. for
+// availability probes where waiting on backoff would only delay startup
+func withoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// do runs fn until it succeeds, fails with a permanent error, runs out of
+// retries or ctx is done
+func (p retryPolicy) do(ctx context.Context, fn func() error) error {
+	maxRetries := p.maxRetries
+	if ctx.Value(noRetryKey{}) != nil {
+		maxRetries = 0
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil || ctx.Err() != nil || !isRetryable(err) || attempt >= maxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(p.delay(err, attempt)):
+		}
+	}
+}
+
+// delay returns how long to wait before retrying err: what the server asked
+// for in Retry-After, or the exponential backoff
+func (p retryPolicy) delay(err error, attempt int) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return min(apiErr.RetryAfter, maxRetryAfter)
+	}
+	return p.backoff(attempt)
+}
+
+// backoff returns the exponential delay before the given retry, with jitter
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	delay := p.baseDelay << attempt
+	if delay <= 0 || delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+	// Up to 25% jitter so concurrent workers don't retry in lockstep
+	return delay - time.Duration(rand.Int63n(int64(delay)/4+1))
+}
+
+// isRetryable reports whether an error is transient: connection problems,
+// rate limiting (429) and 5xx responses
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RateLimited() || apiErr.StatusCode >= 500
+	}
+
+	// Transport failures (connection refused, resets, client timeouts)
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,256 @@
+package embeddings
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================
+// SEARCH OPTIONS AND RERANKING
+// ============================================
+
+// SearchOptions controls which chunks a search returns
+type SearchOptions struct {
+	TopK    int
+	Locale  string   // empty searches every locale
+	Locales []string // search these locales together, overrides Locale
+
+	// Diversity trades relevance for coverage with Maximal Marginal Relevance:
+	// 0 ranks purely by similarity, 1 favors results unlike those already picked
+	Diversity float64
+
+	MaxPerSection int // 0 means no limit
+	MaxPerChapter int // 0 means no limit
+
+	// MinRelevance drops candidates scoring below this fraction of the best
+	// hit, so fewer than TopK may come back; 0 keeps every candidate
+	MinRelevance float64
+
+	Filter *Filter // nil matches every chunk
+
+	exclude func(c *Chunk) bool // drops chunks before scoring, such as the source of find_related
+}
+
+// Filter restricts a search to chunks matching every field that is set
+type Filter struct {
+	ChapterIDs  []string // any of these chapters
+	Sections    []string // section name contains any of these, case-insensitive
+	MinOrder    int      // lowest chapter order, 0 for no bound
+	MaxOrder    int      // highest chapter order, 0 for no bound
+	ContentType string   // "prose" or "code"
+	Tags        []string // chapter has any of these frontmatter tags, case-insensitive
+}
+
+// accepts reports whether a chunk passes the filter and is not excluded
+func (o SearchOptions) accepts(c *Chunk) bool {
+	return o.Filter.matches(c) && (o.exclude == nil || !o.exclude(c))
+}
+
+func (f *Filter) empty() bool {
+	return f == nil || (len(f.ChapterIDs) == 0 && len(f.Sections) == 0 && f.MinOrder == 0 &&
+		f.MaxOrder == 0 && f.ContentType == "" && len(f.Tags) == 0)
+}
+
+func (f *Filter) matches(c *Chunk) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.ChapterIDs) > 0 && !slices.Contains(f.ChapterIDs, c.ChapterID) {
+		return false
+	}
+	if len(f.Sections) > 0 && !slices.ContainsFunc(f.Sections, func(s string) bool {
+		return strings.Contains(strings.ToLower(c.Section), strings.ToLower(s))
+	}) {
+		return false
+	}
+	if (f.MinOrder > 0 && c.ChapterOrder < f.MinOrder) || (f.MaxOrder > 0 && c.ChapterOrder > f.MaxOrder) {
+		return false
+	}
+	if f.ContentType != "" && c.ContentType != f.ContentType {
+		return false
+	}
+	if len(f.Tags) > 0 && !slices.ContainsFunc(f.Tags, func(tag string) bool {
+		return slices.ContainsFunc(c.Tags, func(t string) bool { return strings.EqualFold(t, tag) })
+	}) {
+		return false
+	}
+	return true
+}
+
+// mmrPoolFactor is how many candidates per requested result are considered
+// when reranking
+const mmrPoolFactor = 4
+
+// poolSize returns how many candidates to fetch before reranking
+func (o SearchOptions) poolSize() int {
+	if !o.reranks() {
+		return o.TopK
+	}
+	return max(o.TopK*mmrPoolFactor, o.TopK+20)
+}
+
+func (o SearchOptions) reranks() bool {
+	return o.Diversity > 0 || o.MaxPerSection > 0 || o.MaxPerChapter > 0
+}
+
+// scoredChunk is a search candidate with its similarity to the query
+type scoredChunk struct {
+	chunk Chunk
+	score float64
+}
+
+// partSuffix matches the " (part N)" suffix of sections split into several chunks
+var partSuffix = regexp.MustCompile(`\s+\(part \d+\)$`)
+
+// rerank picks up to TopK candidates that respect the per-section and
+// per-chapter caps. Without diversity they come out by decreasing similarity;
+// with Diversity > 0, each pick maximizes
+//
+//	(1-diversity)*similarity - diversity*max similarity to the picks so far
+//
+// With MinRelevance set, weaker candidates are dropped first.
+func rerank(candidates []scoredChunk, opts SearchOptions) []SemanticResult {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	if opts.MinRelevance > 0 && len(candidates) > 0 {
+		floor := candidates[0].score * opts.MinRelevance
+		cut := sort.Search(len(candidates), func(i int) bool {
+			return candidates[i].score < floor
+		})
+		candidates = candidates[:cut]
+	}
+
+	perSection := make(map[string]int)
+	perChapter := make(map[string]int)
+	allowed := func(c Chunk) bool {
+		return (opts.MaxPerSection <= 0 || perSection[sectionKey(c)] < opts.MaxPerSection) &&
+			(opts.MaxPerChapter <= 0 || perChapter[chapterKey(c)] < opts.MaxPerChapter)
+	}
+
+	var picked []scoredChunk
+	used := make([]bool, len(candidates))
+	lambda := 1 - min(opts.Diversity, 1)
+
+	for len(picked) < opts.TopK {
+		best, bestScore := -1, math.Inf(-1)
+		for i, c := range candidates {
+			if used[i] || !allowed(c.chunk) {
+				continue
+			}
+			if opts.Diversity <= 0 {
+				best = i
+				break
+			}
+
+			redundancy := 0.0
+			for _, p := range picked {
+				redundancy = max(redundancy, c.chunk.Embedding.Dot(p.chunk.Embedding))
+			}
+			if score := lambda*c.score - (1-lambda)*redundancy; score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		used[best] = true
+		perSection[sectionKey(candidates[best].chunk)]++
+		perChapter[chapterKey(candidates[best].chunk)]++
+		picked = append(picked, candidates[best])
+	}
+
+	results := make([]SemanticResult, 0, len(picked))
+	for _, p := range picked {
+		results = append(results, SemanticResult{
+			ChapterID:   p.chunk.ChapterID,
+			ChapterName: p.chunk.ChapterName,
+			Section:     p.chunk.Section,
+			SectionID:   p.chunk.SectionID,
+			Breadcrumb:  p.chunk.Breadcrumb,
+			Content:     p.chunk.Content,
+			Score:       p.score,
+			Locale:      p.chunk.Locale,
+		})
+	}
+	return results
+}
+
+// sectionKey identifies a section across all its "(part N)" chunks
+func sectionKey(c Chunk) string {
+	if c.SectionID != "" {
+		return chapterKey(c) + "#" + c.SectionID
+	}
+	return chapterKey(c) + "\x00" + partSuffix.ReplaceAllString(c.Section, "")
+}
+
+func chapterKey(c Chunk) string {
+	return c.Locale + "\x00" + c.ChapterID
+}
+
+// ============================================
+// RELATED SECTIONS
+// ============================================
+
+// inSection reports whether a chunk belongs to the chapter and, when
+// sectionID is set, to that section
+func inSection(c *Chunk, chapterID, sectionID string) bool {
+	return c.ChapterID == chapterID && (sectionID == "" || c.SectionID == sectionID)
+}
+
+// centroid returns the normalized mean embedding of the chunks of a chapter
+// or section, or an empty vector when none are indexed
+func (v *VectorStore) centroid(locale, chapterID, sectionID string) Vector {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	var sum []float64
+	for i := range v.chunks {
+		chunk := &v.chunks[i]
+		if chunk.Locale != locale || !inSection(chunk, chapterID, sectionID) {
+			continue
+		}
+		values := chunk.Embedding.Float32()
+		if sum == nil {
+			sum = make([]float64, len(values))
+		}
+		if len(values) != len(sum) {
+			continue
+		}
+		for d, x := range values {
+			sum[d] += float64(x)
+		}
+	}
+	return NewVector(sum)
+}
+
+// Related finds the sections most similar to a chapter, or to one of its
+// sections, using the stored embeddings of its chunks. The source itself is
+// excluded: a whole chapter when sectionID is empty, otherwise only that
+// section. No embedding call is made.
+func (e *SemanticEngine) Related(chapterID, sectionID string, opts SearchOptions) (_ []SemanticResult, err error) {
+	if !e.IsIndexed() {
+		return nil, fmt.Errorf("index not built, call IndexChunks first")
+	}
+	defer func(start time.Time) { e.stats.query(start, err) }(time.Now())
+
+	source := e.store(opts.Locale).centroid(opts.Locale, chapterID, sectionID)
+	if source.Len() == 0 {
+		if sectionID != "" {
+			return nil, fmt.Errorf("no indexed chunks for section %s of chapter %s (%s)", sectionID, chapterID, opts.Locale)
+		}
+		return nil, fmt.Errorf("no indexed chunks for chapter %s (%s)", chapterID, opts.Locale)
+	}
+
+	opts.Locales = nil
+	opts.exclude = func(c *Chunk) bool {
+		return inSection(c, chapterID, sectionID)
+	}
+	return e.store(opts.Locale).Search(source, opts), nil
+}
@@ -0,0 +1,182 @@
+package embeddings
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// candidate returns a scored chunk named after its section, with an embedding
+// pointing along the given direction
+func candidate(chapterID, section string, score float64, direction ...float64) scoredChunk {
+	return scoredChunk{
+		chunk: Chunk{ChapterID: chapterID, Section: section, Content: section, Locale: "en", Embedding: NewVector(direction)},
+		score: score,
+	}
+}
+
+// contents returns the content of every result, in order
+func contents(results []SemanticResult) []string {
+	var out []string
+	for _, r := range results {
+		out = append(out, r.Content)
+	}
+	return out
+}
+
+func TestRerank(t *testing.T) {
+	// a (part 2) nearly repeats a (part 1); b and c cover other ground
+	pool := []scoredChunk{
+		candidate("ch1", "b", 0.80, 0, 1, 0),
+		candidate("ch1", "a (part 1)", 0.90, 1, 0, 0),
+		candidate("ch1", "a (part 2)", 0.88, 1, 0.1, 0),
+		candidate("ch2", "c", 0.70, 0, 0, 1),
+	}
+
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want []string
+	}{
+		{"by similarity", SearchOptions{TopK: 3}, []string{"a (part 1)", "a (part 2)", "b"}},
+		{"diversity", SearchOptions{TopK: 3, Diversity: 0.5}, []string{"a (part 1)", "b", "c"}},
+		{"diversity exhausts the pool", SearchOptions{TopK: 10, Diversity: 0.5}, []string{"a (part 1)", "b", "c", "a (part 2)"}},
+		{"pure diversity", SearchOptions{TopK: 2, Diversity: 1}, []string{"a (part 1)", "b"}},
+		{"max per section", SearchOptions{TopK: 3, MaxPerSection: 1}, []string{"a (part 1)", "b", "c"}},
+		{"max per chapter", SearchOptions{TopK: 3, MaxPerChapter: 2}, []string{"a (part 1)", "a (part 2)", "c"}},
+		{"both caps", SearchOptions{TopK: 4, MaxPerSection: 1, MaxPerChapter: 1}, []string{"a (part 1)", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := contents(rerank(slices.Clone(pool), tt.opts))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRerankMinRelevance(t *testing.T) {
+	pool := []scoredChunk{
+		candidate("ch1", "a", 0.60, 1, 0, 0),
+		candidate("ch2", "b", 0.35, 0, 1, 0),
+		candidate("ch3", "c", 0.20, 0, 0, 1),
+		candidate("ch4", "d", -0.10, 0, 1, 1),
+	}
+
+	// Off by default: diversity and caps still fill top_k with weak candidates
+	for _, opts := range []SearchOptions{
+		{TopK: 4},
+		{TopK: 4, Diversity: 0.9},
+		{TopK: 4, MaxPerChapter: 1},
+	} {
+		if got := contents(rerank(slices.Clone(pool), opts)); len(got) != 4 {
+			t.Errorf("%+v: got %v, want all 4", opts, got)
+		}
+	}
+
+	// With it, only candidates close enough to the best hit are picked
+	for _, opts := range []SearchOptions{
+		{TopK: 4, MinRelevance: 0.5},
+		{TopK: 4, MinRelevance: 0.5, Diversity: 0.9},
+		{TopK: 4, MinRelevance: 0.5, MaxPerChapter: 1},
+	} {
+		if got, want := contents(rerank(slices.Clone(pool), opts)), []string{"a", "b"}; !slices.Equal(got, want) {
+			t.Errorf("%+v: got %v, want %v", opts, got, want)
+		}
+	}
+	if got := contents(rerank(slices.Clone(pool), SearchOptions{TopK: 4, MinRelevance: 1})); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("MinRelevance 1: got %v, want only the best hit", got)
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	chunk := &Chunk{
+		ChapterID:    "hooks",
+		Section:      "Custom Hooks (part 2)",
+		ChapterOrder: 5,
+		ContentType:  "code",
+		Tags:         []string{"React", "state"},
+	}
+
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   bool
+	}{
+		{"nil filter", nil, true},
+		{"empty filter", &Filter{}, true},
+		{"chapter", &Filter{ChapterIDs: []string{"intro", "hooks"}}, true},
+		{"other chapter", &Filter{ChapterIDs: []string{"intro"}}, false},
+		{"section contains, any case", &Filter{Sections: []string{"custom hooks"}}, true},
+		{"other section", &Filter{Sections: []string{"testing"}}, false},
+		{"order range", &Filter{MinOrder: 3, MaxOrder: 5}, true},
+		{"below min order", &Filter{MinOrder: 6}, false},
+		{"above max order", &Filter{MaxOrder: 4}, false},
+		{"content type", &Filter{ContentType: "code"}, true},
+		{"other content type", &Filter{ContentType: "prose"}, false},
+		{"tag, any case", &Filter{Tags: []string{"react"}}, true},
+		{"other tag", &Filter{Tags: []string{"go"}}, false},
+		{"every field must match", &Filter{ChapterIDs: []string{"hooks"}, ContentType: "prose"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.matches(chunk); got != tt.want {
+			t.Errorf("%s: matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	var none *Filter
+	if !none.empty() || !(&Filter{}).empty() || (&Filter{MinOrder: 1}).empty() {
+		t.Error("empty reports a filter with a field set, or misses an unset one")
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	store := NewVectorStore()
+	for i, c := range []Chunk{
+		{ChapterID: "hooks", Section: "State", ContentType: "prose", Embedding: NewVector([]float64{1, 0})},
+		{ChapterID: "hooks", Section: "State (part 2)", ContentType: "code", Embedding: NewVector([]float64{1, 0.1})},
+		{ChapterID: "testing", Section: "Mocks", ContentType: "code", Embedding: NewVector([]float64{1, 0.2})},
+		{ChapterID: "testing", Section: "Unrelated", ContentType: "code", Embedding: NewVector([]float64{0, 1})},
+	} {
+		c.ID, c.Locale, c.Content = string(rune('a'+i)), "en", c.Section
+		store.add(c)
+	}
+	query := NewVector([]float64{1, 0})
+
+	// The filter runs before scoring, so the weaker code chunks still fill top_k
+	opts := SearchOptions{TopK: 2, Locale: "en", Filter: &Filter{ContentType: "code"}}
+	if got, want := contents(store.Search(query, opts)), []string{"State (part 2)", "Mocks"}; !slices.Equal(got, want) {
+		t.Errorf("code filter: got %v, want %v", got, want)
+	}
+
+	opts.Filter = &Filter{ChapterIDs: []string{"testing"}}
+	if got, want := contents(store.Search(query, opts)), []string{"Mocks", "Unrelated"}; !slices.Equal(got, want) {
+		t.Errorf("chapter filter: got %v, want %v", got, want)
+	}
+}
+
+func TestQueryStatsCountEveryQuery(t *testing.T) {
+	engine := exportTestEngine(t)
+
+	if _, err := engine.Search(t.Context(), "component state", SearchOptions{TopK: 2, Locale: "en"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Related("hooks", "state", SearchOptions{TopK: 2, Locale: "en"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.TopicMap("en", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Related("missing", "", SearchOptions{TopK: 2, Locale: "en"}); err == nil {
+		t.Fatal("related to a missing chapter: no error")
+	}
+
+	status := engine.Status()
+	if status.Queries != 3 {
+		t.Errorf("queries = %d, want the search, related lookup and topic map", status.Queries)
+	}
+	if !strings.Contains(status.LastError, "missing") {
+		t.Errorf("lastError = %q, want the failed related lookup", status.LastError)
+	}
+}
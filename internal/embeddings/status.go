@@ -0,0 +1,124 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ============================================
+// ENGINE STATUS
+// ============================================
+
+// Status describes the engine as it is actually configured and what it has
+// done since startup
+type Status struct {
+	Provider   Provider `json:"provider"`
+	BaseURL    string   `json:"baseUrl,omitempty"` // empty for the local provider
+	Model      string   `json:"model"`
+	Dimensions int      `json:"dimensions"` // of the indexed vectors, 0 if not indexed
+
+	Indexed         bool           `json:"indexed"`
+	Chunks          int            `json:"chunks"`
+	Locales         map[string]int `json:"locales"` // chunk count per locale
+	Index           *IndexInfo     `json:"index,omitempty"`
+	RebuildRequired bool           `json:"rebuildRequired,omitempty"`
+
+	LastBuildAt       *time.Time `json:"lastBuildAt,omitempty"`       // when the current index was built, here or before a restart
+	LastBuildDuration string     `json:"lastBuildDuration,omitempty"` // only known for builds since startup
+	LastError         string     `json:"lastError,omitempty"`
+	LastErrorAt       *time.Time `json:"lastErrorAt,omitempty"`
+
+	Queries           int             `json:"queries"` // searches, related lookups and topic maps
+	AvgQueryLatencyMs float64         `json:"avgQueryLatencyMs"`
+	QueryCache        QueryCacheStats `json:"queryCache"`
+}
+
+// engineStats records builds, errors and the latency of every query
+type engineStats struct {
+	mu            sync.Mutex
+	buildDuration time.Duration
+	lastError     string
+	lastErrorAt   time.Time
+	queries       int
+	queryTime     time.Duration
+}
+
+// build records a finished index build; cancelled builds are not errors
+func (s *engineStats) build(start time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	s.buildDuration = time.Since(start)
+}
+
+// query records a finished search, related lookup or topic map
+func (s *engineStats) query(start time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.fail(err)
+		return
+	}
+	s.queries++
+	s.queryTime += time.Since(start)
+}
+
+// fail records an error; callers hold s.mu
+func (s *engineStats) fail(err error) {
+	s.lastError = err.Error()
+	s.lastErrorAt = time.Now()
+}
+
+// Status returns a snapshot of the engine configuration, index and activity
+func (e *SemanticEngine) Status() Status {
+	status := Status{
+		Provider:   e.provider,
+		BaseURL:    e.baseURL,
+		Model:      e.model,
+		Locales:    make(map[string]int),
+		QueryCache: e.QueryCacheStats(),
+	}
+
+	e.mu.RLock()
+	info, builtAt := e.info, e.builtAt
+	for locale, store := range e.indexes {
+		count := store.Count()
+		status.Locales[locale] = count
+		status.Chunks += count
+	}
+	e.mu.RUnlock()
+
+	if status.Indexed = status.Chunks > 0; status.Indexed {
+		status.Dimensions = info.Dimensions
+		status.Index = &info
+		status.RebuildRequired = e.CheckIndex() != nil
+	}
+	if !builtAt.IsZero() {
+		status.LastBuildAt = &builtAt
+	}
+
+	e.stats.mu.Lock()
+	defer e.stats.mu.Unlock()
+	if e.stats.buildDuration > 0 {
+		status.LastBuildDuration = e.stats.buildDuration.Round(time.Millisecond).String()
+	}
+	if e.stats.lastError != "" {
+		status.LastError = e.stats.lastError
+		lastErrorAt := e.stats.lastErrorAt
+		status.LastErrorAt = &lastErrorAt
+	}
+	status.Queries = e.stats.queries
+	if e.stats.queries > 0 {
+		avg := e.stats.queryTime / time.Duration(e.stats.queries)
+		status.AvgQueryLatencyMs = float64(avg.Microseconds()) / 1000
+	}
+	return status
+}
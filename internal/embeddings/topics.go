@@ -0,0 +1,298 @@
+package embeddings
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ============================================
+// TOPIC MAP
+// ============================================
+
+// Topic is a cluster of chunks about the same theme
+type Topic struct {
+	ID       int            `json:"id"`
+	Label    string         `json:"label"` // top terms joined with ", "
+	Terms    []string       `json:"terms"`
+	Chunks   int            `json:"chunks"`
+	Sections []TopicSection `json:"sections"`
+}
+
+// TopicSection is a section with chunks in a topic
+type TopicSection struct {
+	ChapterID   string `json:"chapterId"`
+	ChapterName string `json:"chapterName"`
+	Section     string `json:"section"`
+	SectionID   string `json:"sectionId,omitempty"`
+	Chunks      int    `json:"chunks"`
+}
+
+// TopicMap groups the chunks of a locale by theme
+type TopicMap struct {
+	Locale string  `json:"locale"`
+	K      int     `json:"k"`
+	Topics []Topic `json:"topics"`
+}
+
+// topicIterations bounds k-means; it usually converges well before
+const topicIterations = 50
+
+// DefaultTopicCount picks k for n chunks: about sqrt(n/2), between 2 and 20
+func DefaultTopicCount(n int) int {
+	return min(max(int(math.Round(math.Sqrt(float64(n)/2))), 2), 20)
+}
+
+// TopicMap clusters the chunks of a locale into k topics with spherical
+// k-means on their embeddings, labels each topic with its top TF-IDF terms
+// and lists its sections. k <= 0 picks DefaultTopicCount. The result is
+// deterministic for a given index.
+func (e *SemanticEngine) TopicMap(locale string, k, terms int) (_ *TopicMap, err error) {
+	if !e.IsIndexed() {
+		return nil, fmt.Errorf("index not built, call IndexChunks first")
+	}
+	defer func(start time.Time) { e.stats.query(start, err) }(time.Now())
+
+	chunks := e.store(locale).snapshot()
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no indexed chunks for locale %s", locale)
+	}
+	if k <= 0 {
+		k = DefaultTopicCount(len(chunks))
+	}
+	k = min(k, len(chunks))
+	if terms <= 0 {
+		terms = 5
+	}
+
+	vectors := make([][]float32, len(chunks))
+	for i := range chunks {
+		vectors[i] = chunks[i].Embedding.Float32()
+	}
+	assignment := kmeans(vectors, k)
+
+	members := make([][]int, k)
+	for i, cluster := range assignment {
+		members[cluster] = append(members[cluster], i)
+	}
+
+	topTerms := clusterTerms(chunks, members, terms)
+
+	topicMap := &TopicMap{Locale: locale, K: k}
+	for cluster, positions := range members {
+		if len(positions) == 0 {
+			continue
+		}
+		topicMap.Topics = append(topicMap.Topics, Topic{
+			Label:    strings.Join(topTerms[cluster][:min(3, len(topTerms[cluster]))], ", "),
+			Terms:    topTerms[cluster],
+			Chunks:   len(positions),
+			Sections: topicSections(chunks, positions),
+		})
+	}
+
+	sort.SliceStable(topicMap.Topics, func(i, j int) bool {
+		return topicMap.Topics[i].Chunks > topicMap.Topics[j].Chunks
+	})
+	for i := range topicMap.Topics {
+		topicMap.Topics[i].ID = i + 1
+	}
+	return topicMap, nil
+}
+
+// kmeans clusters unit vectors by cosine similarity, seeding with k-means++,
+// and returns the cluster of each vector
+func kmeans(vectors [][]float32, k int) []int {
+	rng := rand.New(rand.NewSource(1))
+	dims := len(vectors[0])
+
+	// k-means++: each next center is drawn proportionally to its distance
+	// from the closest center so far
+	centers := [][]float32{vectors[rng.Intn(len(vectors))]}
+	distance := make([]float64, len(vectors))
+	for len(centers) < k {
+		total := 0.0
+		for i, v := range vectors {
+			closest := math.Inf(1)
+			for _, c := range centers {
+				closest = min(closest, 1-dot32(v, c))
+			}
+			distance[i] = max(closest, 0)
+			total += distance[i]
+		}
+		if total == 0 {
+			break // fewer distinct vectors than k
+		}
+
+		target := rng.Float64() * total
+		next := len(vectors) - 1
+		for i, d := range distance {
+			if target -= d; target < 0 {
+				next = i
+				break
+			}
+		}
+		centers = append(centers, vectors[next])
+	}
+
+	assignment := make([]int, len(vectors))
+	for iter := 0; iter < topicIterations; iter++ {
+		changed := false
+		for i, v := range vectors {
+			best, bestScore := 0, math.Inf(-1)
+			for c, center := range centers {
+				if score := dot32(v, center); score > bestScore {
+					best, bestScore = c, score
+				}
+			}
+			if iter == 0 || assignment[i] != best {
+				assignment[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		// Move each center to the normalized mean of its members; an empty
+		// cluster keeps its center
+		sums := make([][]float64, len(centers))
+		for i, v := range vectors {
+			sum := sums[assignment[i]]
+			if sum == nil {
+				sum = make([]float64, dims)
+				sums[assignment[i]] = sum
+			}
+			for d, x := range v {
+				sum[d] += float64(x)
+			}
+		}
+		for c, sum := range sums {
+			if mean := NewVector(sum); mean.Len() > 0 {
+				centers[c] = mean.Float32()
+			}
+		}
+	}
+	return assignment
+}
+
+func dot32(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float32
+	for i, x := range a {
+		sum += x * b[i]
+	}
+	return float64(sum)
+}
+
+// clusterTerms returns the top terms of each cluster by TF-IDF, treating each
+// cluster as one document so terms common to the whole book rank low
+func clusterTerms(chunks []Chunk, members [][]int, n int) [][]string {
+	counts := make([]map[string]int, len(members))
+	df := make(map[string]int)
+	for cluster, positions := range members {
+		counts[cluster] = make(map[string]int)
+		for _, pos := range positions {
+			for _, term := range labelTerms(chunks[pos].Content) {
+				counts[cluster][term]++
+			}
+		}
+		for term := range counts[cluster] {
+			df[term]++
+		}
+	}
+
+	top := make([][]string, len(members))
+	for cluster, termCounts := range counts {
+		total := 0
+		for _, count := range termCounts {
+			total += count
+		}
+
+		type weighted struct {
+			term   string
+			weight float64
+		}
+		var ranked []weighted
+		for term, count := range termCounts {
+			idf := math.Log(1 + float64(len(members))/float64(df[term]))
+			ranked = append(ranked, weighted{term, float64(count) / float64(total) * idf})
+		}
+		sort.Slice(ranked, func(i, j int) bool {
+			if ranked[i].weight != ranked[j].weight {
+				return ranked[i].weight > ranked[j].weight
+			}
+			return ranked[i].term < ranked[j].term
+		})
+
+		for _, w := range ranked[:min(n, len(ranked))] {
+			top[cluster] = append(top[cluster], w.term)
+		}
+	}
+	return top
+}
+
+// labelTerms splits text into lowercase words readable as labels: stopwords,
+// numbers and words shorter than three letters are dropped
+func labelTerms(text string) []string {
+	var terms []string
+	for _, field := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(field)) < 3 || localStopwords[foldAccents(field)] ||
+			strings.IndexFunc(field, unicode.IsLetter) < 0 {
+			continue
+		}
+		terms = append(terms, field)
+	}
+	return terms
+}
+
+// topicSections groups the chunks of a topic by section, largest first and
+// then in book order
+func topicSections(chunks []Chunk, positions []int) []TopicSection {
+	index := make(map[string]int)
+	var sections []TopicSection
+	var orders []int
+	for _, pos := range positions {
+		chunk := chunks[pos]
+		key := sectionKey(chunk)
+		if i, ok := index[key]; ok {
+			sections[i].Chunks++
+			continue
+		}
+		index[key] = len(sections)
+		sections = append(sections, TopicSection{
+			ChapterID:   chunk.ChapterID,
+			ChapterName: chunk.ChapterName,
+			Section:     partSuffix.ReplaceAllString(chunk.Section, ""),
+			SectionID:   chunk.SectionID,
+			Chunks:      1,
+		})
+		orders = append(orders, chunk.ChapterOrder)
+	}
+
+	order := make([]int, len(sections))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if sections[a].Chunks != sections[b].Chunks {
+			return sections[a].Chunks > sections[b].Chunks
+		}
+		return orders[a] < orders[b]
+	})
+
+	sorted := make([]TopicSection, len(sections))
+	for i, o := range order {
+		sorted[i] = sections[o]
+	}
+	return sorted
+}
@@ -0,0 +1,201 @@
+package embeddings
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// ============================================
+// VECTORS
+// ============================================
+
+// Vector is a unit-length embedding. It is stored either as float32 values or,
+// once quantized, as one int8 code per dimension plus a scale, which is 4x
+// smaller. Since vectors are normalized up front, cosine similarity is a plain
+// dot product.
+type Vector struct {
+	values []float32
+	codes  []int8
+	scale  float32 // value of code 1 when quantized
+}
+
+// NewVector normalizes an embedding into a float32 vector. A nil or zero
+// embedding gives an empty vector.
+func NewVector(embedding []float64) Vector {
+	var norm float64
+	for _, x := range embedding {
+		norm += x * x
+	}
+	if norm == 0 {
+		return Vector{}
+	}
+
+	norm = math.Sqrt(norm)
+	values := make([]float32, len(embedding))
+	for i, x := range embedding {
+		values[i] = float32(x / norm)
+	}
+	return Vector{values: values}
+}
+
+// Len returns the number of dimensions (0 for an empty vector)
+func (v Vector) Len() int {
+	if v.codes != nil {
+		return len(v.codes)
+	}
+	return len(v.values)
+}
+
+// Quantized reports whether the vector holds int8 codes
+func (v Vector) Quantized() bool {
+	return v.codes != nil
+}
+
+// Quantize returns the int8 scalar-quantized form of the vector, scaled so
+// its largest component maps to ±127
+func (v Vector) Quantize() Vector {
+	if v.codes != nil || len(v.values) == 0 {
+		return v
+	}
+
+	var maxAbs float32
+	for _, x := range v.values {
+		maxAbs = max(maxAbs, float32(math.Abs(float64(x))))
+	}
+	if maxAbs == 0 {
+		return Vector{}
+	}
+
+	scale := maxAbs / 127
+	codes := make([]int8, len(v.values))
+	for i, x := range v.values {
+		codes[i] = int8(math.Round(float64(x / scale)))
+	}
+	return Vector{codes: codes, scale: scale}
+}
+
+// Float32 returns the vector values, dequantizing if needed
+func (v Vector) Float32() []float32 {
+	if v.codes == nil {
+		return v.values
+	}
+	values := make([]float32, len(v.codes))
+	for i, c := range v.codes {
+		values[i] = float32(c) * v.scale
+	}
+	return values
+}
+
+// Dot returns the similarity of two vectors, or 0 when their sizes differ.
+// Two quantized vectors are compared in integer arithmetic; a quantized and a
+// float vector are compared asymmetrically, which is more precise.
+func (v Vector) Dot(o Vector) float64 {
+	if v.Len() != o.Len() {
+		return 0
+	}
+
+	switch {
+	case v.codes != nil && o.codes != nil:
+		var sum int32
+		for i, c := range v.codes {
+			sum += int32(c) * int32(o.codes[i])
+		}
+		return float64(sum) * float64(v.scale) * float64(o.scale)
+	case v.codes != nil:
+		return o.Dot(v)
+	case o.codes != nil:
+		var sum float32
+		for i, x := range v.values {
+			sum += x * float32(o.codes[i])
+		}
+		return float64(sum * o.scale)
+	default:
+		var sum float32
+		for i, x := range v.values {
+			sum += x * o.values[i]
+		}
+		return float64(sum)
+	}
+}
+
+// vectorJSON is the persisted form of a Vector: little-endian float32 values
+// or int8 codes, base64-encoded
+type vectorJSON struct {
+	F32   string  `json:"f32,omitempty"`
+	I8    string  `json:"i8,omitempty"`
+	Scale float32 `json:"scale,omitempty"`
+}
+
+func (v Vector) MarshalJSON() ([]byte, error) {
+	if v.codes != nil {
+		raw := make([]byte, len(v.codes))
+		for i, c := range v.codes {
+			raw[i] = byte(c)
+		}
+		return json.Marshal(vectorJSON{I8: base64.StdEncoding.EncodeToString(raw), Scale: v.scale})
+	}
+	if v.values == nil {
+		return []byte("null"), nil
+	}
+
+	raw := make([]byte, 4*len(v.values))
+	for i, x := range v.values {
+		binary.LittleEndian.PutUint32(raw[4*i:], math.Float32bits(x))
+	}
+	return json.Marshal(vectorJSON{F32: base64.StdEncoding.EncodeToString(raw)})
+}
+
+// UnmarshalJSON also accepts a plain array of numbers, as written by index
+// files before version 2
+func (v *Vector) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*v = Vector{}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '[' {
+		var legacy []float64
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return err
+		}
+		*v = NewVector(legacy)
+		return nil
+	}
+
+	var enc vectorJSON
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return err
+	}
+
+	switch {
+	case enc.I8 != "":
+		raw, err := base64.StdEncoding.DecodeString(enc.I8)
+		if err != nil {
+			return fmt.Errorf("invalid vector codes: %w", err)
+		}
+		codes := make([]int8, len(raw))
+		for i, b := range raw {
+			codes[i] = int8(b)
+		}
+		*v = Vector{codes: codes, scale: enc.Scale}
+	case enc.F32 != "":
+		raw, err := base64.StdEncoding.DecodeString(enc.F32)
+		if err != nil {
+			return fmt.Errorf("invalid vector values: %w", err)
+		}
+		if len(raw)%4 != 0 {
+			return fmt.Errorf("invalid vector values: %d bytes is not a multiple of 4", len(raw))
+		}
+		values := make([]float32, len(raw)/4)
+		for i := range values {
+			values[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:]))
+		}
+		*v = Vector{values: values}
+	default:
+		*v = Vector{}
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+// Package eval measures retrieval quality against a golden set of queries
+// with known relevant chapters and sections.
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Search modes
+const (
+	ModeKeyword  = "keyword"
+	ModeSemantic = "semantic"
+	ModeHybrid   = "hybrid" // keyword and semantic results fused by reciprocal rank
+)
+
+// rrfK dampens the weight of top ranks in reciprocal rank fusion; 60 is the
+// value from the original paper and works well without tuning
+const rrfK = 60
+
+// ============================================
+// GOLDEN QUERIES
+// ============================================
+
+// Target is a relevant result: a whole chapter, or one of its sections
+type Target struct {
+	ChapterID string `json:"chapter" yaml:"chapter"`
+	SectionID string `json:"section,omitempty" yaml:"section,omitempty"`
+}
+
+// Query is a question with the results a good search should return
+type Query struct {
+	Query    string   `json:"query" yaml:"query"`
+	Locale   string   `json:"locale,omitempty" yaml:"locale,omitempty"` // defaults to the set locale
+	Expected []Target `json:"expected" yaml:"expected"`
+}
+
+// GoldenSet is the file format of the evaluation queries:
+//
+//	k: 5
+//	locale: es
+//	queries:
+//	  - query: "¿Qué es un puerto?"
+//	    expected:
+//	      - chapter: hexagonal-architecture
+//	        section: puertos
+type GoldenSet struct {
+	K       int     `json:"k,omitempty" yaml:"k,omitempty"`
+	Locale  string  `json:"locale,omitempty" yaml:"locale,omitempty"`
+	Queries []Query `json:"queries" yaml:"queries"`
+}
+
+// LoadGoldenSet reads a golden set from a .json, .yaml or .yml file
+func LoadGoldenSet(path string) (*GoldenSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set GoldenSet
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &set)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &set)
+	default:
+		return nil, fmt.Errorf("unsupported golden set format %q, use .json, .yaml or .yml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+
+	if set.Locale == "" {
+		set.Locale = "es"
+	}
+	for i, q := range set.Queries {
+		if strings.TrimSpace(q.Query) == "" {
+			return nil, fmt.Errorf("query %d has no text", i+1)
+		}
+		if len(q.Expected) == 0 {
+			return nil, fmt.Errorf("query %q has no expected results", q.Query)
+		}
+		if q.Locale == "" {
+			set.Queries[i].Locale = set.Locale
+		}
+	}
+	return &set, nil
+}
+
+// ============================================
+// RUNNING
+// ============================================
+
+// Hit is a search result reduced to what the golden set can match
+type Hit struct {
+	ChapterID string
+	SectionID string
+}
+
+// SearchFunc returns the ranked hits for a query, best first
+type SearchFunc func(ctx context.Context, query, locale string, k int) ([]Hit, error)
+
+// Runner evaluates the search modes it has functions for. Hybrid runs when
+// both Keyword and Semantic are set.
+type Runner struct {
+	Keyword  SearchFunc
+	Semantic SearchFunc
+}
+
+// Modes returns the modes the runner can evaluate
+func (r *Runner) Modes() []string {
+	var modes []string
+	if r.Keyword != nil {
+		modes = append(modes, ModeKeyword)
+	}
+	if r.Semantic != nil {
+		modes = append(modes, ModeSemantic)
+	}
+	if r.Keyword != nil && r.Semantic != nil {
+		modes = append(modes, ModeHybrid)
+	}
+	return modes
+}
+
+// Metrics are averaged over the queries of a mode and locale
+type Metrics struct {
+	Queries int     `json:"queries"`
+	Recall  float64 `json:"recall"` // share of expected targets in the top k
+	MRR     float64 `json:"mrr"`    // mean reciprocal rank of the first relevant hit
+	NDCG    float64 `json:"ndcg"`   // normalized discounted cumulative gain at k
+}
+
+// ModeReport holds the metrics of one search mode
+type ModeReport struct {
+	Mode     string             `json:"mode"`
+	Overall  Metrics            `json:"overall"`
+	ByLocale map[string]Metrics `json:"byLocale"`
+}
+
+// Report is the result of an evaluation
+type Report struct {
+	K     int          `json:"k"`
+	Modes []ModeReport `json:"modes"`
+}
+
+// Run evaluates every query of the set in each of the given modes (all the
+// runner supports when modes is empty) and averages the metrics at k
+func (r *Runner) Run(ctx context.Context, set *GoldenSet, k int, modes []string) (*Report, error) {
+	if k <= 0 {
+		k = set.K
+	}
+	if k <= 0 {
+		k = 5
+	}
+	if len(modes) == 0 {
+		modes = r.Modes()
+	}
+
+	report := &Report{K: k}
+	for _, mode := range modes {
+		search, err := r.search(mode)
+		if err != nil {
+			return nil, err
+		}
+
+		overall := &sums{}
+		byLocale := make(map[string]*sums)
+		for _, q := range set.Queries {
+			hits, err := search(ctx, q.Query, q.Locale, k)
+			if err != nil {
+				return nil, fmt.Errorf("%s search for %q: %w", mode, q.Query, err)
+			}
+
+			m := score(dedupe(hits), q.Expected, k)
+			overall.add(m)
+			if byLocale[q.Locale] == nil {
+				byLocale[q.Locale] = &sums{}
+			}
+			byLocale[q.Locale].add(m)
+		}
+
+		modeReport := ModeReport{Mode: mode, Overall: overall.mean(), ByLocale: make(map[string]Metrics)}
+		for locale, s := range byLocale {
+			modeReport.ByLocale[locale] = s.mean()
+		}
+		report.Modes = append(report.Modes, modeReport)
+	}
+	return report, nil
+}
+
+// search returns the function for a mode
+func (r *Runner) search(mode string) (SearchFunc, error) {
+	switch {
+	case mode == ModeKeyword && r.Keyword != nil:
+		return r.Keyword, nil
+	case mode == ModeSemantic && r.Semantic != nil:
+		return r.Semantic, nil
+	case mode == ModeHybrid && r.Keyword != nil && r.Semantic != nil:
+		return r.hybrid, nil
+	default:
+		return nil, fmt.Errorf("search mode %q not available", mode)
+	}
+}
+
+// hybrid fuses keyword and semantic hits with reciprocal rank fusion
+func (r *Runner) hybrid(ctx context.Context, query, locale string, k int) ([]Hit, error) {
+	keyword, err := r.Keyword(ctx, query, locale, k)
+	if err != nil {
+		return nil, err
+	}
+	semantic, err := r.Semantic(ctx, query, locale, k)
+	if err != nil {
+		return nil, err
+	}
+	return Fuse(k, dedupe(keyword), dedupe(semantic)), nil
+}
+
+// Fuse merges ranked hit lists by reciprocal rank fusion: each hit scores
+// the sum of 1/(60+rank) over the lists it appears in
+func Fuse(k int, lists ...[]Hit) []Hit {
+	scores := make(map[Hit]float64)
+	var hits []Hit
+	for _, list := range lists {
+		for rank, hit := range list {
+			if _, ok := scores[hit]; !ok {
+				hits = append(hits, hit)
+			}
+			scores[hit] += 1 / float64(rrfK+rank+1)
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return scores[hits[i]] > scores[hits[j]]
+	})
+	return hits[:min(k, len(hits))]
+}
+
+// ============================================
+// METRICS
+// ============================================
+
+// dedupe keeps the first hit of each section, so several chunks of the same
+// section count once
+func dedupe(hits []Hit) []Hit {
+	seen := make(map[Hit]bool)
+	var unique []Hit
+	for _, hit := range hits {
+		if !seen[hit] {
+			seen[hit] = true
+			unique = append(unique, hit)
+		}
+	}
+	return unique
+}
+
+// matches reports whether a hit is the target, or inside it when the target
+// is a whole chapter
+func (t Target) matches(hit Hit) bool {
+	return hit.ChapterID == t.ChapterID && (t.SectionID == "" || hit.SectionID == t.SectionID)
+}
+
+// score computes the metrics of one query. Each target is credited once, at
+// the first hit that matches it.
+func score(hits []Hit, expected []Target, k int) Metrics {
+	hits = hits[:min(k, len(hits))]
+	found := make([]bool, len(expected))
+
+	var m Metrics
+	m.Queries = 1
+	dcg := 0.0
+	for rank, hit := range hits {
+		for i, target := range expected {
+			if found[i] || !target.matches(hit) {
+				continue
+			}
+			found[i] = true
+			dcg += 1 / math.Log2(float64(rank+2))
+			if m.MRR == 0 {
+				m.MRR = 1 / float64(rank+1)
+			}
+			break
+		}
+	}
+
+	idcg := 0.0
+	for rank := 0; rank < min(k, len(expected)); rank++ {
+		idcg += 1 / math.Log2(float64(rank+2))
+	}
+
+	hitCount := 0
+	for _, f := range found {
+		if f {
+			hitCount++
+		}
+	}
+	m.Recall = float64(hitCount) / float64(len(expected))
+	m.NDCG = dcg / idcg
+	return m
+}
+
+// sums accumulates metrics to average them
+type sums struct {
+	queries           int
+	recall, mrr, ndcg float64
+}
+
+func (s *sums) add(m Metrics) {
+	s.queries++
+	s.recall += m.Recall
+	s.mrr += m.MRR
+	s.ndcg += m.NDCG
+}
+
+func (s *sums) mean() Metrics {
+	if s.queries == 0 {
+		return Metrics{}
+	}
+	n := float64(s.queries)
+	return Metrics{Queries: s.queries, Recall: s.recall / n, MRR: s.mrr / n, NDCG: s.ndcg / n}
+}
+
+// ============================================
+// OUTPUT
+// ============================================
+
+// String formats the report as a table, one row per mode and locale
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-10s %-8s %7s %9s %7s %7s\n", "mode", "locale", "queries", "recall@"+fmt.Sprint(r.K), "mrr", "ndcg")
+	for _, mode := range r.Modes {
+		locales := make([]string, 0, len(mode.ByLocale))
+		for locale := range mode.ByLocale {
+			locales = append(locales, locale)
+		}
+		sort.Strings(locales)
+
+		row := func(locale string, m Metrics) {
+			fmt.Fprintf(&b, "%-10s %-8s %7d %9.3f %7.3f %7.3f\n", mode.Mode, locale, m.Queries, m.Recall, m.MRR, m.NDCG)
+		}
+		for _, locale := range locales {
+			row(locale, mode.ByLocale[locale])
+		}
+		if len(locales) > 1 {
+			row("all", mode.Overall)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,182 @@
+package eval
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Alan-TheGentleman/gentleman-book-mcp/internal/book"
+	"github.com/Alan-TheGentleman/gentleman-book-mcp/internal/embeddings"
+)
+
+func TestScore(t *testing.T) {
+	hits := []Hit{{"a", "x"}, {"b", "y"}, {"c", ""}, {"d", "z"}}
+	expected := []Target{{ChapterID: "b"}, {ChapterID: "d", SectionID: "z"}, {ChapterID: "e"}}
+
+	m := score(hits, expected, 3)
+
+	// Only b is in the top 3, at rank 2
+	if want := 1.0 / 3; math.Abs(m.Recall-want) > 1e-9 {
+		t.Errorf("recall = %v, want %v", m.Recall, want)
+	}
+	if want := 0.5; m.MRR != want {
+		t.Errorf("mrr = %v, want %v", m.MRR, want)
+	}
+	idcg := 1 + 1/math.Log2(3) + 1/math.Log2(4)
+	if want := (1 / math.Log2(3)) / idcg; math.Abs(m.NDCG-want) > 1e-9 {
+		t.Errorf("ndcg = %v, want %v", m.NDCG, want)
+	}
+}
+
+func TestScoreCreditsTargetsOnce(t *testing.T) {
+	// Two sections of the same expected chapter must not count twice
+	m := score([]Hit{{"a", "x"}, {"a", "y"}}, []Target{{ChapterID: "a"}, {ChapterID: "b"}}, 5)
+	if m.Recall != 0.5 || m.MRR != 1 {
+		t.Errorf("got recall %v and mrr %v, want 0.5 and 1", m.Recall, m.MRR)
+	}
+}
+
+func TestFuse(t *testing.T) {
+	keyword := []Hit{{"a", ""}, {"b", ""}, {"c", ""}}
+	semantic := []Hit{{"b", ""}, {"d", ""}, {"a", ""}}
+
+	got := Fuse(3, keyword, semantic)
+	want := []Hit{{"b", ""}, {"a", ""}, {"d", ""}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLoadGoldenSet(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"golden.yaml": "k: 3\nqueries:\n  - query: puertos\n    locale: en\n    expected:\n      - chapter: hex\n        section: ports\n  - query: capas\n    expected:\n      - chapter: clean\n",
+		"golden.json": `{"k": 3, "queries": [{"query": "puertos", "locale": "en", "expected": [{"chapter": "hex", "section": "ports"}]}, {"query": "capas", "expected": [{"chapter": "clean"}]}]}`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		set, err := LoadGoldenSet(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if set.K != 3 || len(set.Queries) != 2 {
+			t.Fatalf("%s: got k %d and %d queries", name, set.K, len(set.Queries))
+		}
+		if q := set.Queries[0]; q.Locale != "en" || q.Expected[0] != (Target{"hex", "ports"}) {
+			t.Errorf("%s: first query = %+v", name, q)
+		}
+		if q := set.Queries[1]; q.Locale != "es" {
+			t.Errorf("%s: second query locale = %q, want the default es", name, q.Locale)
+		}
+	}
+}
+
+// TestRunLocalProvider evaluates a small book end to end with the offline
+// embedding provider
+func TestRunLocalProvider(t *testing.T) {
+	t.Setenv("QUERY_CACHE_SIZE", "0")
+	parser := book.NewParser(writeBook(t))
+
+	engine, err := embeddings.NewSemanticEngine(embeddings.ProviderLocal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chapters, err := parser.ListChapters("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []embeddings.Chunk
+	for _, chapter := range chapters {
+		for _, text := range parser.ChunkChapter(&chapter, book.DefaultChunkerConfig()) {
+			chunk := embeddings.Chunk{
+				ChapterID:   chapter.ID,
+				ChapterName: chapter.Name,
+				Section:     text.Section,
+				SectionID:   text.SectionID,
+				Breadcrumb:  text.Breadcrumb,
+				Content:     text.Content,
+				Locale:      "en",
+			}
+			chunk.ID = embeddings.ChunkID("en", chapter.ID, chunk.Section, chunk.EmbedText())
+			chunks = append(chunks, chunk)
+		}
+	}
+	ctx := context.Background()
+	if _, err := engine.IndexChunks(ctx, chunks, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &Runner{
+		Keyword: func(ctx context.Context, query, locale string, k int) ([]Hit, error) {
+			results, err := parser.Search(query, locale)
+			hits := make([]Hit, len(results))
+			for i, r := range results {
+				hits[i] = Hit{r.ChapterID, r.SectionID}
+			}
+			return hits, err
+		},
+		Semantic: func(ctx context.Context, query, locale string, k int) ([]Hit, error) {
+			results, err := engine.Search(ctx, query, embeddings.SearchOptions{TopK: k, Locale: locale, MaxPerSection: 1})
+			hits := make([]Hit, len(results))
+			for i, r := range results {
+				hits[i] = Hit{r.ChapterID, r.SectionID}
+			}
+			return hits, err
+		},
+	}
+
+	set := &GoldenSet{Queries: []Query{
+		{Query: "ports and adapters", Locale: "en", Expected: []Target{{"hexagonal", "ports-and-adapters"}}},
+		{Query: "react hooks state", Locale: "en", Expected: []Target{{"react", "hooks"}}},
+		{Query: "unit tests with mocks", Locale: "en", Expected: []Target{{"testing", ""}}},
+	}}
+
+	report, err := runner.Run(ctx, set, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Modes) != 3 {
+		t.Fatalf("got %d modes, want keyword, semantic and hybrid", len(report.Modes))
+	}
+	for _, mode := range report.Modes {
+		m := mode.ByLocale["en"]
+		if m.Queries != 3 || mode.Overall != m {
+			t.Errorf("%s: per-locale %+v does not match overall %+v", mode.Mode, m, mode.Overall)
+		}
+		if mode.Mode == ModeSemantic && (m.Recall != 1 || m.MRR != 1) {
+			t.Errorf("semantic: got %+v, want every target ranked first", m)
+		}
+	}
+}
+
+// writeBook creates a three-chapter English book and returns its path
+func writeBook(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	chapters := map[string]string{
+		"01.mdx": "---\nid: 'hexagonal'\norder: 1\nname: 'Hexagonal Architecture'\n---\n\n## Ports and adapters\n\nPorts define what the domain needs and adapters plug infrastructure into those ports.\n\n## Domain\n\nThe domain model holds the business rules and entities.\n",
+		"02.mdx": "---\nid: 'react'\norder: 2\nname: 'React'\n---\n\n## Hooks\n\nReact hooks like useState keep component state between renders.\n\n## Components\n\nComponents receive props and render markup.\n",
+		"03.mdx": "---\nid: 'testing'\norder: 3\nname: 'Testing'\n---\n\n## Unit tests\n\nUnit tests replace collaborators with mocks and stubs.\n",
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "en"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range chapters {
+		if err := os.WriteFile(filepath.Join(dir, "en", name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
@@ -0,0 +1,323 @@
+// Package fakeembed is a stand-in embeddings server for tests and demos. It
+// speaks the OpenAI (/v1/embeddings) and Ollama (/api/embed and
+// /api/embeddings) protocols, returns deterministic vectors and can inject
+// latency and failures.
+package fakeembed
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Faults the server can inject
+const (
+	FaultRateLimit   = "429"       // 429 Too Many Requests with Retry-After
+	FaultServerError = "500"       // 500 Internal Server Error
+	FaultMalformed   = "malformed" // 200 OK with a truncated JSON body
+)
+
+// Config controls the fake server
+type Config struct {
+	Dimensions int           // vector size, 64 when zero
+	Latency    time.Duration // added to every request
+	RetryAfter time.Duration // sent with FaultRateLimit responses, none when zero
+
+	// Random failures: each request fails with Fault with probability
+	// ErrorRate. FailNext injects failures deterministically instead.
+	Fault     string
+	ErrorRate float64
+
+	// LegacyOllama serves only /api/embeddings, like Ollama before 0.3
+	LegacyOllama bool
+}
+
+// Server is the fake embeddings server
+type Server struct {
+	config Config
+	mux    *http.ServeMux
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	pending  []string // faults for the next requests, in order
+	requests int
+	inputs   int
+}
+
+// New creates a fake server; serve it with net/http or use NewTestServer
+func New(cfg Config) *Server {
+	if cfg.Dimensions <= 0 {
+		cfg.Dimensions = 64
+	}
+
+	s := &Server{config: cfg, mux: http.NewServeMux(), rng: rand.New(rand.NewSource(1))}
+	s.mux.HandleFunc("POST /v1/embeddings", s.handleOpenAI)
+	s.mux.HandleFunc("POST /api/embeddings", s.handleOllamaLegacy)
+	if !cfg.LegacyOllama {
+		s.mux.HandleFunc("POST /api/embed", s.handleOllama)
+	}
+	return s
+}
+
+// NewTestServer starts a fake server on a local port. Point OpenAI clients at
+// URL+"/v1" and Ollama clients at URL.
+func NewTestServer(cfg Config) (*Server, *httptest.Server) {
+	s := New(cfg)
+	return s, httptest.NewServer(s)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// FailNext makes the next n requests fail with fault
+func (s *Server) FailNext(fault string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.pending = append(s.pending, fault)
+	}
+}
+
+// Requests returns how many embedding requests were received, failed ones included
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Inputs returns how many texts were embedded successfully
+func (s *Server) Inputs() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inputs
+}
+
+// begin waits the configured latency, counts the request and returns the
+// fault to inject, if any
+func (s *Server) begin() string {
+	if s.config.Latency > 0 {
+		time.Sleep(s.config.Latency)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if len(s.pending) > 0 {
+		fault := s.pending[0]
+		s.pending = s.pending[1:]
+		return fault
+	}
+	if s.config.ErrorRate > 0 && s.rng.Float64() < s.config.ErrorRate {
+		return s.config.Fault
+	}
+	return ""
+}
+
+// fail writes the response for fault and reports whether it did
+func (s *Server) fail(w http.ResponseWriter, fault string) bool {
+	switch fault {
+	case FaultRateLimit:
+		if s.config.RetryAfter > 0 {
+			w.Header().Set("Retry-After-Ms", strconv.FormatInt(s.config.RetryAfter.Milliseconds(), 10))
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(s.config.RetryAfter.Seconds()))))
+		}
+		writeError(w, http.StatusTooManyRequests, "rate limit reached")
+	case FaultServerError:
+		writeError(w, http.StatusInternalServerError, "internal error")
+	case FaultMalformed:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": [{"embedding": [0.1, `))
+	default:
+		return false
+	}
+	return true
+}
+
+func (s *Server) done(inputs int) {
+	s.mu.Lock()
+	s.inputs += inputs
+	s.mu.Unlock()
+}
+
+// writeError answers in the OpenAI error shape
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"message": message}})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// ============================================
+// PROTOCOLS
+// ============================================
+
+// input accepts a single string or a list of strings, like both APIs
+type input []string
+
+func (in *input) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*in = input{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*in = list
+	return nil
+}
+
+func (s *Server) handleOpenAI(w http.ResponseWriter, r *http.Request) {
+	if s.fail(w, s.begin()) {
+		return
+	}
+
+	var req struct {
+		Input      input  `json:"input"`
+		Model      string `json:"model"`
+		Dimensions int    `json:"dimensions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Input) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid request: input is required")
+		return
+	}
+
+	dims := s.config.Dimensions
+	if req.Dimensions > 0 {
+		dims = req.Dimensions
+	}
+
+	type datum struct {
+		Object    string    `json:"object"`
+		Embedding []float64 `json:"embedding"`
+		Index     int       `json:"index"`
+	}
+	data := make([]datum, len(req.Input))
+	tokens := 0
+	for i, text := range req.Input {
+		data[i] = datum{Object: "embedding", Embedding: Vector(text, dims), Index: i}
+		tokens += len(strings.Fields(text))
+	}
+	s.done(len(req.Input))
+
+	writeJSON(w, map[string]any{
+		"object": "list",
+		"data":   data,
+		"model":  req.Model,
+		"usage":  map[string]int{"prompt_tokens": tokens, "total_tokens": tokens},
+	})
+}
+
+func (s *Server) handleOllama(w http.ResponseWriter, r *http.Request) {
+	if s.failOllama(w, s.begin()) {
+		return
+	}
+
+	var req struct {
+		Model string `json:"model"`
+		Input input  `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Input) == 0 {
+		writeOllamaError(w, http.StatusBadRequest, "invalid request: input is required")
+		return
+	}
+
+	embeddings := make([][]float64, len(req.Input))
+	for i, text := range req.Input {
+		embeddings[i] = Vector(text, s.config.Dimensions)
+	}
+	s.done(len(req.Input))
+
+	writeJSON(w, map[string]any{"model": req.Model, "embeddings": embeddings})
+}
+
+func (s *Server) handleOllamaLegacy(w http.ResponseWriter, r *http.Request) {
+	if s.failOllama(w, s.begin()) {
+		return
+	}
+
+	var req struct {
+		Model  string `json:"model"`
+		Prompt string `json:"prompt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOllamaError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+	s.done(1)
+
+	writeJSON(w, map[string]any{"embedding": Vector(req.Prompt, s.config.Dimensions)})
+}
+
+// failOllama is fail with Ollama's error shape, a plain string
+func (s *Server) failOllama(w http.ResponseWriter, fault string) bool {
+	switch fault {
+	case FaultRateLimit, FaultServerError:
+		if fault == FaultRateLimit && s.config.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(s.config.RetryAfter.Seconds()))))
+		}
+		status, _ := strconv.Atoi(fault)
+		writeOllamaError(w, status, http.StatusText(status))
+		return true
+	}
+	return s.fail(w, fault)
+}
+
+func writeOllamaError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// ============================================
+// VECTORS
+// ============================================
+
+// Vector returns the deterministic unit vector the server answers for text:
+// each lowercased word adds ±1 to a dimension picked by its hash, so texts
+// sharing words are similar. Text without words maps to a fixed dimension.
+func Vector(text string, dims int) []float64 {
+	vector := make([]float64, dims)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+		sign := 1.0
+		if sum&(1<<63) != 0 {
+			sign = -1
+		}
+		vector[sum%uint64(dims)] += sign
+	}
+
+	var norm float64
+	for _, x := range vector {
+		norm += x * x
+	}
+	if norm == 0 {
+		vector[0] = 1
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
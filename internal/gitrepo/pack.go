@@ -0,0 +1,296 @@
+package gitrepo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Packed object types
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+// packFile is a packfile together with its version 2 index
+type packFile struct {
+	packPath string
+	names    []Hash
+	offsets  []int64
+}
+
+// openPack loads the index of a packfile into memory
+func openPack(idxPath string) (*packFile, error) {
+	data, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading pack index %s: %w", idxPath, err)
+	}
+
+	if len(data) < 8+256*4 || !bytes.Equal(data[:4], []byte{0xff, 't', 'O', 'c'}) {
+		return nil, fmt.Errorf("unsupported pack index format: %s", idxPath)
+	}
+	if version := binary.BigEndian.Uint32(data[4:8]); version != 2 {
+		return nil, fmt.Errorf("unsupported pack index version %d: %s", version, idxPath)
+	}
+
+	fanout := data[8 : 8+256*4]
+	count := int(binary.BigEndian.Uint32(fanout[255*4:]))
+
+	namesStart := 8 + 256*4
+	crcStart := namesStart + count*20
+	offsetsStart := crcStart + count*4
+	largeStart := offsetsStart + count*4
+	if len(data) < largeStart {
+		return nil, fmt.Errorf("truncated pack index: %s", idxPath)
+	}
+
+	pack := &packFile{
+		packPath: strings.TrimSuffix(idxPath, ".idx") + ".pack",
+		names:    make([]Hash, count),
+		offsets:  make([]int64, count),
+	}
+
+	for i := 0; i < count; i++ {
+		copy(pack.names[i][:], data[namesStart+i*20:])
+
+		offset := binary.BigEndian.Uint32(data[offsetsStart+i*4:])
+		if offset&0x80000000 != 0 {
+			// Offsets above 2GB are stored in a separate 64-bit table
+			pos := largeStart + int(offset&0x7fffffff)*8
+			if pos+8 > len(data) {
+				return nil, fmt.Errorf("truncated pack index: %s", idxPath)
+			}
+			pack.offsets[i] = int64(binary.BigEndian.Uint64(data[pos:]))
+		} else {
+			pack.offsets[i] = int64(offset)
+		}
+	}
+
+	return pack, nil
+}
+
+// find returns the offset of an object in the packfile
+func (p *packFile) find(hash Hash) (int64, bool) {
+	i := sort.Search(len(p.names), func(i int) bool {
+		return bytes.Compare(p.names[i][:], hash[:]) >= 0
+	})
+	if i < len(p.names) && p.names[i] == hash {
+		return p.offsets[i], true
+	}
+	return 0, false
+}
+
+// findPrefix returns all objects whose hex name starts with prefix
+func (p *packFile) findPrefix(prefix string) []Hash {
+	// Binary search on the even-length part of the prefix, then scan
+	evenLen := len(prefix) &^ 1
+	key, _ := hex.DecodeString(prefix[:evenLen])
+
+	i := sort.Search(len(p.names), func(i int) bool {
+		return bytes.Compare(p.names[i][:len(key)], key) >= 0
+	})
+
+	var matches []Hash
+	for ; i < len(p.names) && bytes.HasPrefix(p.names[i][:], key); i++ {
+		if strings.HasPrefix(p.names[i].String(), prefix) {
+			matches = append(matches, p.names[i])
+		}
+	}
+	return matches
+}
+
+// readAt reads and fully resolves the object stored at offset
+func (p *packFile) readAt(offset int64, repo *Repository) (string, []byte, error) {
+	file, err := os.Open(p.packPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("error opening pack %s: %w", p.packPath, err)
+	}
+	defer file.Close()
+
+	return p.readEntry(file, offset, repo, 0)
+}
+
+func (p *packFile) readEntry(file *os.File, offset int64, repo *Repository, depth int) (string, []byte, error) {
+	if depth > 64 {
+		return "", nil, fmt.Errorf("delta chain too deep in %s", p.packPath)
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(file, offset, 1<<62))
+
+	// Entry header: type in bits 4-6 of the first byte, size as a little-endian varint
+	b, err := reader.ReadByte()
+	if err != nil {
+		return "", nil, err
+	}
+	kind := (b >> 4) & 0x07
+	size := int64(b & 0x0f)
+	shift := uint(4)
+	for b&0x80 != 0 {
+		if b, err = reader.ReadByte(); err != nil {
+			return "", nil, err
+		}
+		size |= int64(b&0x7f) << shift
+		shift += 7
+	}
+
+	switch kind {
+	case packCommit, packTree, packBlob, packTag:
+		content, err := inflate(reader, size)
+		if err != nil {
+			return "", nil, fmt.Errorf("corrupt pack entry at %d: %w", offset, err)
+		}
+		return packTypeName(kind), content, nil
+
+	case packOfsDelta:
+		// Base offset is a big-endian varint with an implicit +1 per continuation byte
+		if b, err = reader.ReadByte(); err != nil {
+			return "", nil, err
+		}
+		rel := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = reader.ReadByte(); err != nil {
+				return "", nil, err
+			}
+			rel = ((rel + 1) << 7) | int64(b&0x7f)
+		}
+		delta, err := inflate(reader, size)
+		if err != nil {
+			return "", nil, fmt.Errorf("corrupt pack entry at %d: %w", offset, err)
+		}
+		baseType, base, err := p.readEntry(file, offset-rel, repo, depth+1)
+		if err != nil {
+			return "", nil, err
+		}
+		content, err := applyDelta(base, delta)
+		return baseType, content, err
+
+	case packRefDelta:
+		var baseHash Hash
+		if _, err := io.ReadFull(reader, baseHash[:]); err != nil {
+			return "", nil, err
+		}
+		delta, err := inflate(reader, size)
+		if err != nil {
+			return "", nil, fmt.Errorf("corrupt pack entry at %d: %w", offset, err)
+		}
+		baseType, base, err := repo.readObject(baseHash)
+		if err != nil {
+			return "", nil, err
+		}
+		content, err := applyDelta(base, delta)
+		return baseType, content, err
+	}
+
+	return "", nil, fmt.Errorf("unknown pack entry type %d at %d", kind, offset)
+}
+
+func packTypeName(kind byte) string {
+	switch kind {
+	case packCommit:
+		return typeCommit
+	case packTree:
+		return typeTree
+	case packBlob:
+		return typeBlob
+	default:
+		return typeTag
+	}
+}
+
+func inflate(r io.Reader, size int64) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	content := make([]byte, size)
+	if _, err := io.ReadFull(zr, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// applyDelta rebuilds an object from its base and a git delta
+func applyDelta(base, delta []byte) ([]byte, error) {
+	readSize := func() int {
+		size, shift := 0, uint(0)
+		for len(delta) > 0 {
+			b := delta[0]
+			delta = delta[1:]
+			size |= int(b&0x7f) << shift
+			shift += 7
+			if b&0x80 == 0 {
+				break
+			}
+		}
+		return size
+	}
+
+	if srcSize := readSize(); srcSize != len(base) {
+		return nil, fmt.Errorf("delta base size mismatch")
+	}
+	dstSize := readSize()
+	result := make([]byte, 0, dstSize)
+
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		if op&0x80 != 0 {
+			// Copy from base: offset and size bytes are present per flag bit
+			var offset, size int
+			for i := uint(0); i < 4; i++ {
+				if op&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, fmt.Errorf("truncated delta")
+					}
+					offset |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			for i := uint(0); i < 3; i++ {
+				if op&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, fmt.Errorf("truncated delta")
+					}
+					size |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > len(base) {
+				return nil, fmt.Errorf("delta copy out of range")
+			}
+			result = append(result, base[offset:offset+size]...)
+		} else if op != 0 {
+			// Insert literal data
+			n := int(op)
+			if n > len(delta) {
+				return nil, fmt.Errorf("truncated delta")
+			}
+			result = append(result, delta[:n]...)
+			delta = delta[n:]
+		} else {
+			return nil, fmt.Errorf("invalid delta opcode")
+		}
+	}
+
+	if len(result) != dstSize {
+		return nil, fmt.Errorf("delta result size mismatch")
+	}
+	return result, nil
+}
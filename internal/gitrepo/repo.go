@@ -0,0 +1,770 @@
+package gitrepo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hash is a SHA-1 object name
+type Hash [20]byte
+
+// ZeroHash is the empty hash, used for "object not found"
+var ZeroHash Hash
+
+// ErrNotExist is returned by ReadFile when the path is not in the commit
+var ErrNotExist = errors.New("file does not exist")
+
+// String returns the hex representation of the hash
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// Short returns the abbreviated hex representation of the hash
+func (h Hash) Short() string {
+	return h.String()[:7]
+}
+
+// IsZero reports whether the hash is the zero hash
+func (h Hash) IsZero() bool {
+	return h == ZeroHash
+}
+
+// ParseHash parses a full 40 character hex object name
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	if len(s) != 40 {
+		return h, fmt.Errorf("invalid object name: %s", s)
+	}
+	if _, err := hex.Decode(h[:], []byte(s)); err != nil {
+		return h, fmt.Errorf("invalid object name %s: %w", s, err)
+	}
+	return h, nil
+}
+
+// Object types as stored in loose objects and packfiles
+const (
+	typeCommit = "commit"
+	typeTree   = "tree"
+	typeBlob   = "blob"
+	typeTag    = "tag"
+)
+
+// Signature is the author or committer of a commit
+type Signature struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	When  time.Time `json:"when"`
+}
+
+// Commit is a parsed commit object
+type Commit struct {
+	Hash      Hash
+	Tree      Hash
+	Parents   []Hash
+	Author    Signature
+	Committer Signature
+	Message   string
+}
+
+// Subject returns the first line of the commit message
+func (c *Commit) Subject() string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+	return strings.TrimSpace(subject)
+}
+
+// Repository gives read-only access to a local git repository
+type Repository struct {
+	workTree  string
+	gitDir    string
+	commonDir string
+
+	packsOnce sync.Once
+	packs     []*packFile
+	packsErr  error
+}
+
+// Open finds the git repository containing path, walking up the directory tree
+func Open(path string) (*Repository, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		info, err := os.Stat(dotGit)
+		if err == nil {
+			gitDir := dotGit
+			if !info.IsDir() {
+				// Worktrees and submodules use a ".git" file pointing to the real directory
+				gitDir, err = readGitDirFile(dotGit)
+				if err != nil {
+					return nil, err
+				}
+			}
+			return newRepository(dir, gitDir)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("no git repository found for %s", path)
+		}
+		dir = parent
+	}
+}
+
+func readGitDirFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", path, err)
+	}
+	line := strings.TrimSpace(string(content))
+	gitDir, ok := strings.CutPrefix(line, "gitdir:")
+	if !ok {
+		return "", fmt.Errorf("invalid gitdir file: %s", path)
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	return gitDir, nil
+}
+
+func newRepository(workTree, gitDir string) (*Repository, error) {
+	repo := &Repository{
+		workTree:  workTree,
+		gitDir:    gitDir,
+		commonDir: gitDir,
+	}
+
+	// Linked worktrees keep objects and shared refs in the common directory
+	if content, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(content))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		repo.commonDir = commonDir
+	}
+
+	if content, err := os.ReadFile(filepath.Join(repo.commonDir, "config")); err == nil {
+		if strings.Contains(string(content), "objectformat = sha256") {
+			return nil, fmt.Errorf("sha256 repositories are not supported")
+		}
+	}
+
+	return repo, nil
+}
+
+// WorkTree returns the root directory of the working tree
+func (r *Repository) WorkTree() string {
+	return r.workTree
+}
+
+// RelPath converts a file path into a slash-separated path relative to the work tree
+func (r *Repository) RelPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+
+	rel, err := filepath.Rel(r.workTree, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the repository at %s", path, r.workTree)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// ============================================
+// REVISIONS AND REFS
+// ============================================
+
+// ResolveRevision resolves a revision expression to a commit hash.
+// Supported forms: full or abbreviated hashes, HEAD, branch, tag and remote
+// names, and any of those followed by ~N or ^N suffixes.
+func (r *Repository) ResolveRevision(rev string) (Hash, error) {
+	rev = strings.TrimSpace(rev)
+	if rev == "" {
+		return ZeroHash, fmt.Errorf("empty revision")
+	}
+
+	// Split the base name from the ancestry suffixes
+	base := rev
+	suffixes := ""
+	if i := strings.IndexAny(rev, "~^"); i != -1 {
+		base, suffixes = rev[:i], rev[i:]
+	}
+
+	hash, err := r.resolveName(base)
+	if err != nil {
+		return ZeroHash, err
+	}
+	hash, err = r.peelToCommit(hash)
+	if err != nil {
+		return ZeroHash, err
+	}
+
+	for suffixes != "" {
+		op := suffixes[0]
+		suffixes = suffixes[1:]
+
+		end := 0
+		for end < len(suffixes) && suffixes[end] >= '0' && suffixes[end] <= '9' {
+			end++
+		}
+		n := 1
+		if end > 0 {
+			n, _ = strconv.Atoi(suffixes[:end])
+		}
+		suffixes = suffixes[end:]
+
+		commit, err := r.Commit(hash)
+		if err != nil {
+			return ZeroHash, err
+		}
+
+		switch op {
+		case '~':
+			for i := 0; i < n; i++ {
+				if len(commit.Parents) == 0 {
+					return ZeroHash, fmt.Errorf("revision %s goes past the root commit", rev)
+				}
+				hash = commit.Parents[0]
+				if i+1 < n {
+					if commit, err = r.Commit(hash); err != nil {
+						return ZeroHash, err
+					}
+				}
+			}
+		case '^':
+			if n == 0 {
+				continue
+			}
+			if n > len(commit.Parents) {
+				return ZeroHash, fmt.Errorf("revision %s: commit has no parent %d", rev, n)
+			}
+			hash = commit.Parents[n-1]
+		}
+	}
+
+	return hash, nil
+}
+
+func (r *Repository) resolveName(name string) (Hash, error) {
+	if name == "HEAD" {
+		return r.resolveRef("HEAD", 0)
+	}
+
+	candidates := []string{
+		name,
+		"refs/" + name,
+		"refs/tags/" + name,
+		"refs/heads/" + name,
+		"refs/remotes/" + name,
+		"refs/remotes/" + name + "/HEAD",
+	}
+	for _, ref := range candidates {
+		if hash, err := r.resolveRef(ref, 0); err == nil {
+			return hash, nil
+		}
+	}
+
+	if len(name) == 40 {
+		if hash, err := ParseHash(name); err == nil {
+			return hash, nil
+		}
+	}
+	if len(name) >= 4 && len(name) < 40 && isHex(name) {
+		return r.resolvePrefix(strings.ToLower(name))
+	}
+
+	return ZeroHash, fmt.Errorf("unknown revision: %s", name)
+}
+
+func (r *Repository) resolveRef(ref string, depth int) (Hash, error) {
+	if depth > 10 {
+		return ZeroHash, fmt.Errorf("symbolic ref loop at %s", ref)
+	}
+	if !validRef(ref) {
+		return ZeroHash, fmt.Errorf("invalid ref name: %s", ref)
+	}
+
+	// Per-worktree refs (HEAD) live in gitDir, shared refs in commonDir
+	for _, dir := range []string{r.gitDir, r.commonDir} {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref)))
+		if err != nil {
+			continue
+		}
+		value := strings.TrimSpace(string(content))
+		if target, ok := strings.CutPrefix(value, "ref:"); ok {
+			return r.resolveRef(strings.TrimSpace(target), depth+1)
+		}
+		return ParseHash(value)
+	}
+
+	packed, err := r.packedRefs()
+	if err != nil {
+		return ZeroHash, err
+	}
+	if hash, ok := packed[ref]; ok {
+		return hash, nil
+	}
+
+	return ZeroHash, fmt.Errorf("ref not found: %s", ref)
+}
+
+// validRef rejects ref names that could point outside the git directory, as
+// git itself does: no absolute paths, backslashes or ".." anywhere
+func validRef(ref string) bool {
+	return ref != "" && !strings.HasPrefix(ref, "/") && !filepath.IsAbs(ref) &&
+		!strings.Contains(ref, "..") && !strings.Contains(ref, "\\")
+}
+
+func (r *Repository) packedRefs() (map[string]Hash, error) {
+	refs := make(map[string]Hash)
+
+	file, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return refs, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Skip comments and peeled tag lines
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		hashStr, name, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		if hash, err := ParseHash(hashStr); err == nil {
+			refs[name] = hash
+		}
+	}
+
+	return refs, scanner.Err()
+}
+
+func (r *Repository) resolvePrefix(prefix string) (Hash, error) {
+	var matches []Hash
+
+	// Loose objects
+	dir := filepath.Join(r.commonDir, "objects", prefix[:2])
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			if strings.HasPrefix(prefix[:2]+entry.Name(), prefix) {
+				if hash, err := ParseHash(prefix[:2] + entry.Name()); err == nil {
+					matches = append(matches, hash)
+				}
+			}
+		}
+	}
+
+	// Packed objects
+	packs, err := r.loadPacks()
+	if err != nil {
+		return ZeroHash, err
+	}
+	for _, pack := range packs {
+		matches = append(matches, pack.findPrefix(prefix)...)
+	}
+
+	matches = uniqueHashes(matches)
+	switch len(matches) {
+	case 0:
+		return ZeroHash, fmt.Errorf("unknown revision: %s", prefix)
+	case 1:
+		return matches[0], nil
+	default:
+		return ZeroHash, fmt.Errorf("ambiguous revision: %s", prefix)
+	}
+}
+
+func (r *Repository) peelToCommit(hash Hash) (Hash, error) {
+	for i := 0; i < 10; i++ {
+		objType, content, err := r.readObject(hash)
+		if err != nil {
+			return ZeroHash, err
+		}
+		switch objType {
+		case typeCommit:
+			return hash, nil
+		case typeTag:
+			// Annotated tag: follow the "object" header
+			target := ""
+			for _, line := range strings.Split(string(content), "\n") {
+				if value, ok := strings.CutPrefix(line, "object "); ok {
+					target = value
+					break
+				}
+			}
+			if hash, err = ParseHash(target); err != nil {
+				return ZeroHash, err
+			}
+		default:
+			return ZeroHash, fmt.Errorf("object %s is a %s, not a commit", hash.Short(), objType)
+		}
+	}
+	return ZeroHash, fmt.Errorf("tag chain too deep at %s", hash.Short())
+}
+
+// ============================================
+// COMMITS, TREES AND BLOBS
+// ============================================
+
+// Commit reads and parses a commit object
+func (r *Repository) Commit(hash Hash) (*Commit, error) {
+	objType, content, err := r.readObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if objType != typeCommit {
+		return nil, fmt.Errorf("object %s is a %s, not a commit", hash.Short(), objType)
+	}
+
+	commit := &Commit{Hash: hash}
+	headers, message, _ := strings.Cut(string(content), "\n\n")
+	commit.Message = message
+
+	for _, line := range strings.Split(headers, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			commit.Tree, err = ParseHash(value)
+		case "parent":
+			var parent Hash
+			parent, err = ParseHash(value)
+			commit.Parents = append(commit.Parents, parent)
+		case "author":
+			commit.Author = parseSignature(value)
+		case "committer":
+			commit.Committer = parseSignature(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed commit %s: %w", hash.Short(), err)
+		}
+	}
+
+	return commit, nil
+}
+
+// parseSignature parses "Name <email> 1700000000 +0100"
+func parseSignature(value string) Signature {
+	var sig Signature
+
+	start := strings.Index(value, "<")
+	end := strings.Index(value, ">")
+	if start == -1 || end < start {
+		sig.Name = strings.TrimSpace(value)
+		return sig
+	}
+	sig.Name = strings.TrimSpace(value[:start])
+	sig.Email = value[start+1 : end]
+
+	fields := strings.Fields(value[end+1:])
+	if len(fields) >= 1 {
+		if seconds, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			sig.When = time.Unix(seconds, 0)
+			if len(fields) >= 2 {
+				if tz, err := time.Parse("-0700", fields[1]); err == nil {
+					sig.When = sig.When.In(tz.Location())
+				}
+			}
+		}
+	}
+
+	return sig
+}
+
+type treeEntry struct {
+	mode string
+	name string
+	hash Hash
+}
+
+func (r *Repository) readTree(hash Hash) ([]treeEntry, error) {
+	objType, content, err := r.readObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	if objType != typeTree {
+		return nil, fmt.Errorf("object %s is a %s, not a tree", hash.Short(), objType)
+	}
+
+	var entries []treeEntry
+	for len(content) > 0 {
+		space := bytes.IndexByte(content, ' ')
+		null := bytes.IndexByte(content, 0)
+		if space == -1 || null == -1 || null < space || null+21 > len(content) {
+			return nil, fmt.Errorf("malformed tree %s", hash.Short())
+		}
+		entry := treeEntry{
+			mode: string(content[:space]),
+			name: string(content[space+1 : null]),
+		}
+		copy(entry.hash[:], content[null+1:null+21])
+		entries = append(entries, entry)
+		content = content[null+21:]
+	}
+
+	return entries, nil
+}
+
+// PathHash returns the blob hash of a file at a commit, or ZeroHash if the
+// file does not exist in that commit
+func (r *Repository) PathHash(commit *Commit, path string) (Hash, error) {
+	hash := commit.Tree
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, part := range parts {
+		entries, err := r.readTree(hash)
+		if err != nil {
+			return ZeroHash, err
+		}
+
+		found := false
+		for _, entry := range entries {
+			if entry.name != part {
+				continue
+			}
+			isDir := entry.mode == "40000"
+			if isDir == (i == len(parts)-1) {
+				// Directory where a file was expected, or the other way around
+				return ZeroHash, nil
+			}
+			hash = entry.hash
+			found = true
+			break
+		}
+		if !found {
+			return ZeroHash, nil
+		}
+	}
+
+	return hash, nil
+}
+
+// ReadFile returns the contents of a file at the given commit
+func (r *Repository) ReadFile(commitHash Hash, path string) ([]byte, error) {
+	commit, err := r.Commit(commitHash)
+	if err != nil {
+		return nil, err
+	}
+	blobHash, err := r.PathHash(commit, path)
+	if err != nil {
+		return nil, err
+	}
+	if blobHash.IsZero() {
+		return nil, fmt.Errorf("%w: %s at %s", ErrNotExist, path, commitHash.Short())
+	}
+
+	objType, content, err := r.readObject(blobHash)
+	if err != nil {
+		return nil, err
+	}
+	if objType != typeBlob {
+		return nil, fmt.Errorf("%s at %s is not a file", path, commitHash.Short())
+	}
+	return content, nil
+}
+
+// Log returns the commits reachable from start that changed the file at path,
+// newest first. Like "git log -- path", merges are only listed when the file
+// differs from every parent. A limit of 0 or less returns all commits.
+func (r *Repository) Log(start Hash, path string, limit int) ([]*Commit, error) {
+	blobs := make(map[Hash]Hash)
+	commits := make(map[Hash]*Commit)
+
+	load := func(hash Hash) (*Commit, Hash, error) {
+		commit, ok := commits[hash]
+		if !ok {
+			var err error
+			if commit, err = r.Commit(hash); err != nil {
+				return nil, ZeroHash, err
+			}
+			commits[hash] = commit
+		}
+		blob, ok := blobs[hash]
+		if !ok {
+			var err error
+			if blob, err = r.PathHash(commit, path); err != nil {
+				return nil, ZeroHash, err
+			}
+			blobs[hash] = blob
+		}
+		return commit, blob, nil
+	}
+
+	if _, _, err := load(start); err != nil {
+		return nil, err
+	}
+
+	var result []*Commit
+	seen := map[Hash]bool{start: true}
+	queue := []Hash{start}
+
+	for len(queue) > 0 && (limit <= 0 || len(result) < limit) {
+		// Always process the most recent commit first (every queued commit is loaded)
+		sort.SliceStable(queue, func(i, j int) bool {
+			return commits[queue[i]].Committer.When.After(commits[queue[j]].Committer.When)
+		})
+
+		hash := queue[0]
+		queue = queue[1:]
+		commit, blob, _ := load(hash)
+
+		var next []Hash
+		include := true
+		if len(commit.Parents) == 0 {
+			include = !blob.IsZero()
+		}
+		for _, parent := range commit.Parents {
+			_, parentBlob, err := load(parent)
+			if err != nil {
+				return nil, err
+			}
+			if parentBlob == blob {
+				// Unchanged relative to this parent: follow only it
+				include = false
+				next = []Hash{parent}
+				break
+			}
+			next = append(next, parent)
+		}
+
+		if include {
+			result = append(result, commit)
+		}
+		for _, parent := range next {
+			if !seen[parent] {
+				seen[parent] = true
+				queue = append(queue, parent)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// ============================================
+// OBJECT STORAGE
+// ============================================
+
+// readObject reads an object from loose storage or any packfile
+func (r *Repository) readObject(hash Hash) (string, []byte, error) {
+	objType, content, err := r.readLooseObject(hash)
+	if err == nil {
+		return objType, content, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", nil, err
+	}
+
+	packs, err := r.loadPacks()
+	if err != nil {
+		return "", nil, err
+	}
+	for _, pack := range packs {
+		if offset, ok := pack.find(hash); ok {
+			return pack.readAt(offset, r)
+		}
+	}
+
+	return "", nil, fmt.Errorf("object not found: %s", hash)
+}
+
+func (r *Repository) readLooseObject(hash Hash) (string, []byte, error) {
+	name := hash.String()
+	file, err := os.Open(filepath.Join(r.commonDir, "objects", name[:2], name[2:]))
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	zr, err := zlib.NewReader(file)
+	if err != nil {
+		return "", nil, fmt.Errorf("corrupt object %s: %w", name, err)
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, fmt.Errorf("corrupt object %s: %w", name, err)
+	}
+
+	header, content, ok := bytes.Cut(data, []byte{0})
+	if !ok {
+		return "", nil, fmt.Errorf("corrupt object %s: missing header", name)
+	}
+	objType, sizeStr, _ := strings.Cut(string(header), " ")
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil || size != len(content) {
+		return "", nil, fmt.Errorf("corrupt object %s: size mismatch", name)
+	}
+
+	return objType, content, nil
+}
+
+func (r *Repository) loadPacks() ([]*packFile, error) {
+	r.packsOnce.Do(func() {
+		pattern := filepath.Join(r.commonDir, "objects", "pack", "*.idx")
+		indexes, err := filepath.Glob(pattern)
+		if err != nil {
+			r.packsErr = err
+			return
+		}
+		for _, idx := range indexes {
+			pack, err := openPack(idx)
+			if err != nil {
+				r.packsErr = err
+				return
+			}
+			r.packs = append(r.packs, pack)
+		}
+	})
+	return r.packs, r.packsErr
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func uniqueHashes(hashes []Hash) []Hash {
+	seen := make(map[Hash]bool)
+	var unique []Hash
+	for _, h := range hashes {
+		if !seen[h] {
+			seen[h] = true
+			unique = append(unique, h)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,373 @@
+package gitrepo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fixture is a small repository built with the git CLI:
+//
+//	c1 ── c2 (tag v1, annotated) ── c3 ── merge
+//	        └── side ──────────────────┘
+//
+// chapter.mdx changes in c1, c2 and c3; notes.md only on the side branch.
+type fixture struct {
+	dir                             string
+	c1, c2, c3, side, merge         string
+	chapterV1, chapterV2, chapterV3 string
+}
+
+// git runs a git command in dir with a fixed identity and date
+func git(t *testing.T, dir string, date int, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	stamp := fmt.Sprintf("%d +0100", 1700000000+date*3600)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Ada", "GIT_AUTHOR_EMAIL=ada@example.com", "GIT_AUTHOR_DATE="+stamp,
+		"GIT_COMMITTER_NAME=Ada", "GIT_COMMITTER_EMAIL=ada@example.com", "GIT_COMMITTER_DATE="+stamp,
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_SYSTEM=/dev/null",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// chapterText is long enough for git to store later versions as deltas
+func chapterText(edit string) string {
+	var b strings.Builder
+	b.WriteString("---\nid: 'intro'\n---\n\n## Intro\n\n")
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&b, "Line %d of the chapter, with enough text to be worth a delta.\n", i)
+		if i == 100 {
+			b.WriteString(edit)
+		}
+	}
+	return b.String()
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	f := &fixture{
+		dir:       t.TempDir(),
+		chapterV1: chapterText(""),
+		chapterV2: chapterText("A paragraph added in the second revision.\n"),
+		chapterV3: chapterText("A paragraph rewritten in the third revision.\n"),
+	}
+	write := func(name, content string) {
+		path := filepath.Join(f.dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit := func(date int, message string) string {
+		git(t, f.dir, date, "add", "-A")
+		git(t, f.dir, date, "commit", "-q", "-m", message)
+		return git(t, f.dir, date, "rev-parse", "HEAD")
+	}
+
+	git(t, f.dir, 0, "init", "-q", "-b", "main")
+	write("book/es/chapter.mdx", f.chapterV1)
+	f.c1 = commit(1, "First draft")
+	write("book/es/chapter.mdx", f.chapterV2)
+	f.c2 = commit(2, "Second draft\n\nWith a body.")
+	git(t, f.dir, 2, "tag", "-a", "v1", "-m", "Release 1")
+	git(t, f.dir, 2, "tag", "light", f.c1)
+
+	git(t, f.dir, 3, "checkout", "-q", "-b", "side")
+	write("notes.md", "side notes\n")
+	f.side = commit(3, "Side notes")
+
+	git(t, f.dir, 4, "checkout", "-q", "main")
+	write("book/es/chapter.mdx", f.chapterV3)
+	f.c3 = commit(4, "Third draft")
+	git(t, f.dir, 5, "merge", "-q", "--no-ff", "-m", "Merge side", "side")
+	f.merge = git(t, f.dir, 5, "rev-parse", "HEAD")
+
+	return f
+}
+
+// clone copies the fixture's repository so it can be repacked
+func (f *fixture) clone(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "clone")
+	git(t, f.dir, 6, "clone", "-q", "--no-local", f.dir, dir)
+	git(t, dir, 6, "branch", "-q", "side", "origin/side")
+	return dir
+}
+
+func open(t *testing.T, dir string) *Repository {
+	t.Helper()
+	repo, err := Open(filepath.Join(dir, "book", "es"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestResolveRevision(t *testing.T) {
+	f := newFixture(t)
+	repo := open(t, f.dir)
+
+	tests := []struct {
+		rev  string
+		want string
+	}{
+		{"HEAD", f.merge},
+		{"main", f.merge},
+		{"refs/heads/main", f.merge},
+		{"HEAD^", f.c3},
+		{"HEAD^1", f.c3},
+		{"HEAD^2", f.side},
+		{"HEAD~2", f.c2},
+		{"HEAD^2~1", f.c2},
+		{"HEAD^^^", f.c1},
+		{"v1", f.c2}, // annotated tag, peeled to its commit
+		{"v1~1", f.c1},
+		{"light", f.c1},
+		{"side", f.side},
+		{f.c1, f.c1},
+		{f.c2[:7], f.c2},
+		{strings.ToUpper(f.c3[:10]), f.c3},
+	}
+	for _, tt := range tests {
+		got, err := repo.ResolveRevision(tt.rev)
+		if err != nil {
+			t.Errorf("%s: %v", tt.rev, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s = %s, want %s", tt.rev, got.Short(), tt.want[:7])
+		}
+	}
+
+	for _, rev := range []string{"", "nope", "HEAD~10", "HEAD^3", "ffffffff"} {
+		if _, err := repo.ResolveRevision(rev); err == nil {
+			t.Errorf("%q: expected an error", rev)
+		}
+	}
+
+	// Ref names never reach files outside the git directory
+	outside := filepath.Join(f.dir, "outside")
+	if err := os.WriteFile(outside, []byte(f.c1+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, rev := range []string{"../outside", "refs/../../outside", outside, `..\outside`} {
+		if hash, err := repo.ResolveRevision(rev); err == nil {
+			t.Errorf("%q resolved to %s, want an error", rev, hash.Short())
+		}
+	}
+}
+
+func TestCommit(t *testing.T) {
+	f := newFixture(t)
+	repo := open(t, f.dir)
+
+	hash, _ := ParseHash(f.c2)
+	commit, err := repo.Commit(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Subject() != "Second draft" || commit.Author.Name != "Ada" || commit.Author.Email != "ada@example.com" {
+		t.Errorf("got %+v", commit)
+	}
+	if commit.Author.When.Unix() != 1700000000+2*3600 {
+		t.Errorf("author date %v", commit.Author.When)
+	}
+	if _, offset := commit.Author.When.Zone(); offset != 3600 {
+		t.Errorf("author zone offset %d, want 3600", offset)
+	}
+	if len(commit.Parents) != 1 || commit.Parents[0].String() != f.c1 {
+		t.Errorf("parents %v", commit.Parents)
+	}
+
+	tree := commit.Tree
+	if _, err := repo.Commit(tree); err == nil {
+		t.Error("reading a tree as a commit should fail")
+	}
+}
+
+func TestReadFileAndLog(t *testing.T) {
+	f := newFixture(t)
+	checkHistory(t, f, open(t, f.dir))
+}
+
+// TestPackedRepository reads the fixture after git moved every object into
+// packfiles, once with offset deltas and once with reference deltas
+func TestPackedRepository(t *testing.T) {
+	f := newFixture(t)
+
+	for _, refDeltas := range []bool{false, true} {
+		t.Run(fmt.Sprintf("refDeltas=%v", refDeltas), func(t *testing.T) {
+			dir := f.clone(t)
+			git(t, dir, 6, "-c", fmt.Sprintf("repack.useDeltaBaseOffset=%v", !refDeltas), "repack", "-q", "-a", "-d", "-f")
+			git(t, dir, 6, "pack-refs", "--all")
+			git(t, dir, 6, "prune")
+
+			loose, _ := filepath.Glob(filepath.Join(dir, ".git", "objects", "??", "*"))
+			if len(loose) > 0 {
+				t.Fatalf("%d loose objects left after repacking", len(loose))
+			}
+			if _, err := os.Stat(filepath.Join(dir, ".git", "refs", "tags", "v1")); !os.IsNotExist(err) {
+				t.Fatal("tags should only be in packed-refs")
+			}
+			if !strings.Contains(verifyPack(t, dir), "chain length") {
+				t.Fatal("the pack has no deltas")
+			}
+
+			repo := open(t, dir)
+			checkHistory(t, f, repo)
+			checkEveryObject(t, dir, repo)
+
+			hash, err := repo.ResolveRevision("v1")
+			if err != nil || hash.String() != f.c2 {
+				t.Errorf("packed annotated tag v1 = %v, %v", hash, err)
+			}
+		})
+	}
+}
+
+func TestLooseObjects(t *testing.T) {
+	f := newFixture(t)
+	checkEveryObject(t, f.dir, open(t, f.dir))
+}
+
+// checkHistory checks the chapter's contents and log against the fixture
+func checkHistory(t *testing.T, f *fixture, repo *Repository) {
+	t.Helper()
+	for rev, want := range map[string]string{f.c1: f.chapterV1, "v1": f.chapterV2, "HEAD": f.chapterV3} {
+		hash, err := repo.ResolveRevision(rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := repo.ReadFile(hash, "book/es/chapter.mdx")
+		if err != nil {
+			t.Fatalf("%s: %v", rev, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: chapter content differs", rev)
+		}
+	}
+
+	head, _ := repo.ResolveRevision("HEAD")
+	if _, err := repo.ReadFile(head, "book/es/missing.mdx"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("reading a missing file: got %v, want ErrNotExist", err)
+	}
+	if _, err := repo.ReadFile(head, "book/es"); err == nil {
+		t.Error("reading a directory should fail")
+	}
+
+	// The merge and the side commit do not touch the chapter
+	commits, err := repo.Log(head, "book/es/chapter.mdx", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range commits {
+		got = append(got, c.Hash.String())
+	}
+	if want := []string{f.c3, f.c2, f.c1}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("chapter log = %v, want c3 c2 c1", got)
+	}
+
+	commits, err = repo.Log(head, "notes.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 1 || commits[0].Hash.String() != f.side {
+		t.Errorf("notes log = %v, want the side commit", commits)
+	}
+
+	commits, err = repo.Log(head, "book/es/chapter.mdx", 2)
+	if err != nil || len(commits) != 2 {
+		t.Errorf("limited log = %d commits, %v", len(commits), err)
+	}
+}
+
+// checkEveryObject compares every object git knows with what the package reads
+func checkEveryObject(t *testing.T, dir string, repo *Repository) {
+	t.Helper()
+	list := git(t, dir, 6, "cat-file", "--batch-all-objects", "--batch-check=%(objectname) %(objecttype)")
+	for _, line := range strings.Split(list, "\n") {
+		name, wantType, _ := strings.Cut(line, " ")
+		hash, err := ParseHash(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gotType, content, err := repo.readObject(hash)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if gotType != wantType {
+			t.Errorf("%s: type %s, want %s", name, gotType, wantType)
+		}
+
+		cmd := exec.Command("git", "cat-file", wantType, name)
+		cmd.Dir = dir
+		want, err := cmd.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != string(want) {
+			t.Errorf("%s: %s content differs from git", name, wantType)
+		}
+	}
+}
+
+func verifyPack(t *testing.T, dir string) string {
+	t.Helper()
+	indexes, _ := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "*.idx"))
+	if len(indexes) != 1 {
+		t.Fatalf("got %d pack indexes, want 1", len(indexes))
+	}
+	return git(t, dir, 6, "verify-pack", "-v", indexes[0])
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello, world")
+
+	tests := []struct {
+		name  string
+		delta []byte
+		want  string
+		fails bool
+	}{
+		// Sizes 12 -> 13; copy "hello" (offset 0, size 5), insert "!!", copy ", world"
+		{"copy and insert", []byte{12, 14, 0x90, 5, 2, '!', '!', 0x91, 5, 7}, "hello!!, world", false},
+		{"insert only", []byte{12, 3, 3, 'a', 'b', 'c'}, "abc", false},
+		{"wrong base size", []byte{11, 3, 3, 'a', 'b', 'c'}, "", true},
+		{"wrong result size", []byte{12, 4, 3, 'a', 'b', 'c'}, "", true},
+		{"copy out of range", []byte{12, 5, 0x91, 10, 5}, "", true},
+		{"truncated insert", []byte{12, 3, 3, 'a'}, "", true},
+		{"truncated copy", []byte{12, 5, 0x91, 10}, "", true},
+		{"zero opcode", []byte{12, 1, 0}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := applyDelta(base, tt.delta)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %q", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}